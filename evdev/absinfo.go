@@ -0,0 +1,28 @@
+package evdev
+
+// AbsInfo is the kernel's input_absinfo for one axis.
+type AbsInfo struct {
+	Value      int32
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32
+	Flat       int32
+	Resolution int32 // units per mm, 0 if unknown
+}
+
+// Normalize maps v from [Minimum, Maximum] onto [0, 1].
+func (a AbsInfo) Normalize(v int32) float32 {
+	if a.Maximum <= a.Minimum {
+		return 0
+	}
+	return float32(v-a.Minimum) / float32(a.Maximum-a.Minimum)
+}
+
+// Length is the axis' length in mm, 0 if the device does not report its
+// resolution.
+func (a AbsInfo) Length() float32 {
+	if a.Resolution <= 0 {
+		return 0
+	}
+	return float32(a.Maximum-a.Minimum) / float32(a.Resolution)
+}
@@ -0,0 +1,21 @@
+package evdev
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// GetAbsInfo queries the range of an axis with the EVIOCGABS ioctl.
+func GetAbsInfo(file *os.File, axis uint16) (AbsInfo, error) {
+	var info AbsInfo
+	// _IOR('E', 0x40 + axis, struct input_absinfo)
+	request := uintptr(2<<30 | unsafe.Sizeof(info)<<16 | 'E'<<8 | (0x40 + uintptr(axis)))
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), request,
+		uintptr(unsafe.Pointer(&info)))
+	if errno != 0 {
+		return info, &os.PathError{Op: "EVIOCGABS", Path: file.Name(), Err: errno}
+	}
+
+	return info, nil
+}
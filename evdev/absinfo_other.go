@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package evdev
+
+import (
+	"errors"
+	"os"
+)
+
+// GetAbsInfo is only supported on Linux.
+func GetAbsInfo(file *os.File, axis uint16) (AbsInfo, error) {
+	return AbsInfo{}, errors.New("evdev: EVIOCGABS needs Linux")
+}
@@ -0,0 +1,191 @@
+package evdev
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DevicesPath lists the input devices known to the kernel.
+const DevicesPath = "/proc/bus/input/devices"
+
+// Device is one entry of DevicesPath.
+type Device struct {
+	Name string
+	Path string // /dev/input/eventN, empty if the device has no event handler
+	key  bitmap
+	abs  bitmap
+}
+
+// HasKey reports whether the device can send the EV_KEY code.
+func (d Device) HasKey(code uint16) bool {
+	return d.key.has(code)
+}
+
+// HasAbs reports whether the device has the EV_ABS axis.
+func (d Device) HasAbs(code uint16) bool {
+	return d.abs.has(code)
+}
+
+// IsTouch reports whether the device is a touchpad or touchscreen, that is it
+// has absolute X and Y axes and reports BTN_TOUCH.
+func (d Device) IsTouch() bool {
+	return d.Path != "" && d.HasAbs(ABS_X) && d.HasAbs(ABS_Y) && d.HasKey(BTN_TOUCH)
+}
+
+// ListDevices reads DevicesPath.
+func ListDevices() ([]Device, error) {
+	file, err := os.Open(DevicesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseDevices(file)
+}
+
+// ParseDevices parses the format of DevicesPath, blank line separated blocks
+// of "N: Name=", "H: Handlers=" and "B: KEY=" style lines.
+func ParseDevices(r io.Reader) ([]Device, error) {
+	var devices []Device
+	var device Device
+	inDevice := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if inDevice {
+				devices = append(devices, device)
+			}
+			device, inDevice = Device{}, false
+			continue
+		}
+		inDevice = true
+
+		if len(line) < 3 || line[1] != ':' {
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimSpace(line[2:]), "=")
+		switch line[0] {
+		case 'N':
+			if key == "Name" {
+				device.Name = strings.Trim(value, `"`)
+			}
+		case 'H':
+			for _, handler := range strings.Fields(value) {
+				if strings.HasPrefix(handler, "event") {
+					device.Path = filepath.Join("/dev/input", handler)
+				}
+			}
+		case 'B':
+			bits, err := parseBitmap(value)
+			if err != nil {
+				return nil, fmt.Errorf("evdev: %s: %v", device.Name, err)
+			}
+			switch key {
+			case "KEY":
+				device.key = bits
+			case "ABS":
+				device.abs = bits
+			}
+		}
+	}
+	if inDevice {
+		devices = append(devices, device)
+	}
+
+	return devices, scanner.Err()
+}
+
+// FindDevice picks a device by selector: a /dev/input path, a case
+// insensitive part of the device name, or when empty the first touch
+// device, preferring ones named touchpad.
+func FindDevice(devices []Device, selector string) (Device, error) {
+	if strings.HasPrefix(selector, "/") {
+		for _, device := range devices {
+			if device.Path == selector {
+				return device, nil
+			}
+		}
+		// Not listed, trust the caller and let opening it fail if wrong.
+		// The capabilities are unknown, see QueryDevice.
+		return Device{Name: selector, Path: selector}, nil
+	}
+
+	var candidates []Device
+	for _, device := range devices {
+		if selector != "" {
+			if device.Path != "" && strings.Contains(strings.ToLower(device.Name),
+				strings.ToLower(selector)) {
+				return device, nil
+			}
+		} else if device.IsTouch() {
+			candidates = append(candidates, device)
+		}
+	}
+
+	for _, device := range candidates {
+		if strings.Contains(strings.ToLower(device.Name), "touchpad") {
+			return device, nil
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0], nil
+	}
+
+	if selector != "" {
+		return Device{}, fmt.Errorf("evdev: no input device named %q", selector)
+	}
+	return Device{}, fmt.Errorf("evdev: no device with ABS_X, ABS_Y and BTN_TOUCH")
+}
+
+// bitmap is a capability mask, word 0 holding bits 0 to 63.
+type bitmap []uint64
+
+// Sizes of the EVIOCGBIT buffers, KEY_MAX and ABS_MAX bits rounded up.
+const (
+	keyBytes = 0x2ff/8 + 1
+	absBytes = 0x3f/8 + 1
+)
+
+// bytesBitmap converts the byte array EVIOCGBIT fills in, byte 0 holding
+// bits 0 to 7, to a bitmap with words as wide as parseBitmap's.
+func bytesBitmap(b []byte) bitmap {
+	bits := make(bitmap, (len(b)*8+strconv.IntSize-1)/strconv.IntSize)
+	for i, v := range b {
+		for bit := 0; bit < 8; bit++ {
+			if v&(1<<bit) != 0 {
+				code := i*8 + bit
+				bits[code/strconv.IntSize] |= 1 << (code % strconv.IntSize)
+			}
+		}
+	}
+
+	return bits
+}
+
+// parseBitmap parses the hex words of a "B:" line. Words are printed most
+// significant first and are as wide as a long on the running kernel.
+func parseBitmap(s string) (bitmap, error) {
+	words := strings.Fields(s)
+	bits := make(bitmap, len(words))
+	for i, word := range words {
+		v, err := strconv.ParseUint(word, 16, strconv.IntSize)
+		if err != nil {
+			return nil, err
+		}
+		bits[len(words)-1-i] = v
+	}
+
+	return bits, nil
+}
+
+func (b bitmap) has(code uint16) bool {
+	word, bit := int(code)/strconv.IntSize, uint(code)%strconv.IntSize
+	return word < len(b) && b[word]&(1<<bit) != 0
+}
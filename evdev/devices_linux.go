@@ -0,0 +1,48 @@
+package evdev
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// QueryDevice asks an opened event device for its name and EV_KEY and
+// EV_ABS capabilities with the EVIOCGNAME and EVIOCGBIT ioctls, for paths
+// FindDevice could not look up in DevicesPath.
+func QueryDevice(file *os.File) (Device, error) {
+	device := Device{Path: file.Name()}
+
+	name := make([]byte, 256)
+	n, err := ioctlRead(file, "EVIOCGNAME", 0x06, name)
+	if err != nil {
+		return device, err
+	}
+	device.Name = strings.TrimRight(string(name[:n]), "\x00")
+
+	key := make([]byte, keyBytes)
+	if _, err := ioctlRead(file, "EVIOCGBIT", 0x20+EV_KEY, key); err != nil {
+		return device, err
+	}
+	abs := make([]byte, absBytes)
+	if _, err := ioctlRead(file, "EVIOCGBIT", 0x20+EV_ABS, abs); err != nil {
+		return device, err
+	}
+	device.key, device.abs = bytesBitmap(key), bytesBitmap(abs)
+
+	return device, nil
+}
+
+// ioctlRead runs the 'E' ioctl nr reading into buf, returning how many
+// bytes the kernel filled in.
+func ioctlRead(file *os.File, op string, nr uintptr, buf []byte) (int, error) {
+	// _IOC(_IOC_READ, 'E', nr, len(buf))
+	request := uintptr(2<<30 | uintptr(len(buf))<<16 | 'E'<<8 | nr)
+	n, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), request,
+		uintptr(unsafe.Pointer(&buf[0])))
+	if errno != 0 {
+		return 0, &os.PathError{Op: op, Path: file.Name(), Err: errno}
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package evdev
+
+import (
+	"errors"
+	"os"
+)
+
+// QueryDevice is only supported on Linux.
+func QueryDevice(file *os.File) (Device, error) {
+	return Device{Path: file.Name()}, errors.New("evdev: EVIOCGBIT needs Linux")
+}
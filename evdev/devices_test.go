@@ -0,0 +1,190 @@
+package evdev
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Captured from a laptop running a 64 bit kernel, the touchscreen moved in
+// front of the touchpad and the last block left without a trailing blank
+// line.
+const devicesText = `I: Bus=0011 Vendor=0001 Product=0001 Version=ab41
+N: Name="AT Translated Set 2 keyboard"
+P: Phys=isa0060/serio0/input0
+S: Sysfs=/devices/platform/i8042/serio0/input/input0
+U: Uniq=
+H: Handlers=sysrq kbd event0 leds
+B: PROP=0
+B: EV=120013
+B: KEY=402000000 3803078f800d001 feffffdfffefffff fffffffffffffffe
+B: MSC=10
+B: LED=7
+
+I: Bus=0018 Vendor=04f3 Product=2516 Version=0100
+N: Name="ELAN Touchscreen"
+P: Phys=i2c-ELAN0732:00
+S: Sysfs=/devices/pci0000:00/0000:00:15.0/i2c_designware.0/i2c-1/i2c-ELAN0732:00/0018:04F3:2516.0001/input/input12
+U: Uniq=
+H: Handlers=mouse1 event7
+B: PROP=2
+B: EV=1b
+B: KEY=400 0 0 0 0 0
+B: ABS=3273800000000003
+B: MSC=20
+
+I: Bus=0011 Vendor=0002 Product=0007 Version=01b1
+N: Name="SynPS/2 Synaptics TouchPad"
+P: Phys=isa0060/serio1/input0
+S: Sysfs=/devices/platform/i8042/serio1/input/input5
+U: Uniq=
+H: Handlers=mouse0 event5
+B: PROP=5
+B: EV=b
+B: KEY=e520 10000 0 0 0 0
+B: ABS=660800011000003
+
+I: Bus=0019 Vendor=0000 Product=0001 Version=0000
+N: Name="Power Button"
+P: Phys=LNXPWRBN/button/input0
+S: Sysfs=/devices/LNXSYSTM:00/LNXPWRBN:00/input/input2
+U: Uniq=
+H: Handlers=kbd event2
+B: PROP=0
+B: EV=3
+B: KEY=10000000000000 0`
+
+func parseTestDevices(t *testing.T) []Device {
+	if strconv.IntSize != 64 {
+		t.Skip("the capture has 64 bit bitmap words")
+	}
+	devices, err := ParseDevices(strings.NewReader(devicesText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return devices
+}
+
+func TestParseDevices(t *testing.T) {
+	devices := parseTestDevices(t)
+
+	want := []struct {
+		name    string
+		path    string
+		touch   bool
+		mtSlots bool
+	}{
+		{"AT Translated Set 2 keyboard", "/dev/input/event0", false, false},
+		{"ELAN Touchscreen", "/dev/input/event7", true, true},
+		{"SynPS/2 Synaptics TouchPad", "/dev/input/event5", true, true},
+		{"Power Button", "/dev/input/event2", false, false},
+	}
+	if len(devices) != len(want) {
+		t.Fatalf("got %d devices, want %d", len(devices), len(want))
+	}
+	for i, w := range want {
+		d := devices[i]
+		if d.Name != w.name || d.Path != w.path {
+			t.Errorf("device %d is %q at %q, want %q at %q",
+				i, d.Name, d.Path, w.name, w.path)
+		}
+		if d.IsTouch() != w.touch {
+			t.Errorf("%s: IsTouch() = %v, want %v", d.Name, d.IsTouch(), w.touch)
+		}
+		if d.HasAbs(ABS_MT_SLOT) != w.mtSlots {
+			t.Errorf("%s: HasAbs(ABS_MT_SLOT) = %v, want %v",
+				d.Name, d.HasAbs(ABS_MT_SLOT), w.mtSlots)
+		}
+	}
+
+	touchpad := devices[2]
+	for _, code := range []uint16{ABS_PRESSURE, ABS_MT_POSITION_X,
+		ABS_MT_POSITION_Y, ABS_MT_TRACKING_ID, ABS_MT_PRESSURE} {
+		if !touchpad.HasAbs(code) {
+			t.Errorf("touchpad is missing axis %#x", code)
+		}
+	}
+	if devices[0].HasKey(BTN_TOUCH) {
+		t.Error("keyboard reports BTN_TOUCH")
+	}
+}
+
+func TestParseDevicesBadBitmap(t *testing.T) {
+	_, err := ParseDevices(strings.NewReader("N: Name=\"broken\"\nB: KEY=xyz\n"))
+	if err == nil {
+		t.Error("ParseDevices accepted a bitmap that is not hex")
+	}
+}
+
+func TestFindDevice(t *testing.T) {
+	devices := parseTestDevices(t)
+
+	tests := []struct {
+		selector string
+		want     string // device path, empty for an error
+	}{
+		{"", "/dev/input/event5"}, // touchpads win over touchscreens
+		{"elan", "/dev/input/event7"},
+		{"SYNAPTICS", "/dev/input/event5"},
+		{"/dev/input/event0", "/dev/input/event0"},
+		{"/dev/input/event99", "/dev/input/event99"}, // trusted as given
+		{"wacom", ""},
+	}
+	for _, tt := range tests {
+		device, err := FindDevice(devices, tt.selector)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("FindDevice(%q) = %s, want an error", tt.selector, device.Path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FindDevice(%q): %v", tt.selector, err)
+		} else if device.Path != tt.want {
+			t.Errorf("FindDevice(%q) = %s, want %s", tt.selector, device.Path, tt.want)
+		}
+	}
+
+	// Without the touchpad the touchscreen is the only candidate, and
+	// without either there is nothing to pick.
+	if device, err := FindDevice(devices[:2], ""); err != nil || device.Path != "/dev/input/event7" {
+		t.Errorf("FindDevice without a touchpad = %s, %v, want the touchscreen",
+			device.Path, err)
+	}
+	if _, err := FindDevice(devices[3:], ""); err == nil {
+		t.Error("FindDevice found a touch device among the power buttons")
+	}
+}
+
+func TestBytesBitmap(t *testing.T) {
+	// The touchpad's ABS mask as EVIOCGBIT fills it in, low byte first.
+	abs := []byte{0x03, 0x00, 0x00, 0x11, 0x00, 0x80, 0x60, 0x06}
+	bits := bytesBitmap(abs)
+	touchpad := parseTestDevices(t)[2]
+	for code := uint16(0); code < 64; code++ {
+		if bits.has(code) != touchpad.HasAbs(code) {
+			t.Errorf("axis %#x: bytesBitmap has %v, ParseDevices %v",
+				code, bits.has(code), touchpad.HasAbs(code))
+		}
+	}
+
+	// Bit 0x14a is bit 2 of byte 41.
+	key := make([]byte, keyBytes)
+	key[41] = 1 << 2
+	if !bytesBitmap(key).has(BTN_TOUCH) {
+		t.Error("bytesBitmap lost BTN_TOUCH")
+	}
+}
+
+func TestQueryDeviceNotEvdev(t *testing.T) {
+	file, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer file.Close()
+
+	if device, err := QueryDevice(file); err == nil {
+		t.Errorf("QueryDevice(%s) = %+v, want an error", os.DevNull, device)
+	}
+}
@@ -0,0 +1,116 @@
+// Package evdev decodes the binary input_event records the Linux kernel
+// writes to /dev/input/eventN.
+package evdev
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Event types, codes and values from linux/input-event-codes.h.
+const (
+	EV_SYN = 0x00
+	EV_KEY = 0x01
+	EV_ABS = 0x03
+
+	SYN_REPORT  = 0
+	SYN_DROPPED = 3
+
+	BTN_TOUCH = 0x14a
+
+	ABS_X              = 0x00
+	ABS_Y              = 0x01
+	ABS_PRESSURE       = 0x18
+	ABS_MT_SLOT        = 0x2f
+	ABS_MT_POSITION_X  = 0x35
+	ABS_MT_POSITION_Y  = 0x36
+	ABS_MT_TRACKING_ID = 0x39
+	ABS_MT_PRESSURE    = 0x3a
+)
+
+// Event is one input_event record.
+type Event struct {
+	Time  time.Time
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// EventSize is the size of an input_event on this platform: a struct
+// timeval made of two longs followed by type, code and value.
+const EventSize = 2*strconv.IntSize/8 + 8
+
+// Decoder reads events from a stream laid out like /dev/input/eventN.
+type Decoder struct {
+	r        io.Reader
+	buf      [EventSize]byte
+	frame    []Event
+	dropping bool
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// ReadEvent returns the next raw event.
+func (d *Decoder) ReadEvent() (Event, error) {
+	if _, err := io.ReadFull(d.r, d.buf[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Event{}, errors.New("evdev: truncated input_event")
+		}
+		return Event{}, err
+	}
+
+	var sec, usec int64
+	word := strconv.IntSize / 8
+	if word == 8 {
+		sec = int64(binary.LittleEndian.Uint64(d.buf[0:]))
+		usec = int64(binary.LittleEndian.Uint64(d.buf[8:]))
+	} else {
+		sec = int64(int32(binary.LittleEndian.Uint32(d.buf[0:])))
+		usec = int64(int32(binary.LittleEndian.Uint32(d.buf[4:])))
+	}
+	rest := d.buf[2*word:]
+
+	return Event{
+		Time:  time.Unix(sec, usec*1000),
+		Type:  binary.LittleEndian.Uint16(rest[0:]),
+		Code:  binary.LittleEndian.Uint16(rest[2:]),
+		Value: int32(binary.LittleEndian.Uint32(rest[4:])),
+	}, nil
+}
+
+// ReadFrame returns the events of the next complete frame, without the
+// SYN_REPORT that ends it. When the kernel reports SYN_DROPPED the partial
+// frames up to the next SYN_REPORT are skipped, as the kernel documentation
+// asks. The returned slice is reused by the next call.
+func (d *Decoder) ReadFrame() ([]Event, error) {
+	d.frame = d.frame[:0]
+	for {
+		ev, err := d.ReadEvent()
+		if err != nil {
+			return nil, err
+		}
+
+		if ev.Type == EV_SYN {
+			switch ev.Code {
+			case SYN_DROPPED:
+				d.dropping = true
+				d.frame = d.frame[:0]
+			case SYN_REPORT:
+				if d.dropping {
+					d.dropping = false
+					continue
+				}
+				return d.frame, nil
+			}
+			continue
+		}
+		if !d.dropping {
+			d.frame = append(d.frame, ev)
+		}
+	}
+}
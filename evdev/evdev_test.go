@@ -0,0 +1,150 @@
+package evdev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// record lays out events the way the kernel writes them to /dev/input.
+func record(events ...Event) []byte {
+	var buf bytes.Buffer
+	for _, ev := range events {
+		sec, usec := ev.Time.Unix(), int64(ev.Time.Nanosecond()/1000)
+		if strconv.IntSize == 64 {
+			binary.Write(&buf, binary.LittleEndian, sec)
+			binary.Write(&buf, binary.LittleEndian, usec)
+		} else {
+			binary.Write(&buf, binary.LittleEndian, int32(sec))
+			binary.Write(&buf, binary.LittleEndian, int32(usec))
+		}
+		binary.Write(&buf, binary.LittleEndian, ev.Type)
+		binary.Write(&buf, binary.LittleEndian, ev.Code)
+		binary.Write(&buf, binary.LittleEndian, ev.Value)
+	}
+	return buf.Bytes()
+}
+
+var t0 = time.Unix(1700000000, 250000000)
+
+func abs(code uint16, value int32) Event {
+	return Event{Time: t0, Type: EV_ABS, Code: code, Value: value}
+}
+
+func key(code uint16, value int32) Event {
+	return Event{Time: t0, Type: EV_KEY, Code: code, Value: value}
+}
+
+func syn(code uint16) Event {
+	return Event{Time: t0, Type: EV_SYN, Code: code}
+}
+
+func equalFrames(a, b []Event) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Time.Equal(b[i].Time) || a[i].Type != b[i].Type ||
+			a[i].Code != b[i].Code || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadEvent(t *testing.T) {
+	want := Event{Time: time.Unix(1700000000, 123456000), Type: EV_ABS,
+		Code: ABS_X, Value: -42}
+	d := NewDecoder(bytes.NewReader(record(want)))
+
+	got, err := d.ReadEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalFrames([]Event{got}, []Event{want}) {
+		t.Errorf("ReadEvent() = %+v, want %+v", got, want)
+	}
+	if _, err := d.ReadEvent(); err != io.EOF {
+		t.Errorf("ReadEvent() at the end = %v, want io.EOF", err)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		frames [][]Event
+	}{
+		{
+			"normal",
+			[]Event{
+				key(BTN_TOUCH, 1), abs(ABS_X, 100), abs(ABS_Y, 200), syn(SYN_REPORT),
+				abs(ABS_X, 110), syn(SYN_REPORT),
+			},
+			[][]Event{
+				{key(BTN_TOUCH, 1), abs(ABS_X, 100), abs(ABS_Y, 200)},
+				{abs(ABS_X, 110)},
+			},
+		},
+		{
+			"empty frame",
+			[]Event{syn(SYN_REPORT), abs(ABS_X, 5), syn(SYN_REPORT)},
+			[][]Event{{}, {abs(ABS_X, 5)}},
+		},
+		{
+			// Everything from the partial frame before SYN_DROPPED up to
+			// the next SYN_REPORT is thrown away.
+			"dropped",
+			[]Event{
+				abs(ABS_X, 1), syn(SYN_DROPPED), abs(ABS_X, 2), abs(ABS_Y, 3),
+				syn(SYN_REPORT), abs(ABS_X, 4), syn(SYN_REPORT),
+			},
+			[][]Event{{abs(ABS_X, 4)}},
+		},
+	}
+
+	for _, tt := range tests {
+		d := NewDecoder(bytes.NewReader(record(tt.events...)))
+		for i, want := range tt.frames {
+			got, err := d.ReadFrame()
+			if err != nil {
+				t.Fatalf("%s: frame %d: %v", tt.name, i, err)
+			}
+			if !equalFrames(got, want) {
+				t.Errorf("%s: frame %d = %+v, want %+v", tt.name, i, got, want)
+			}
+		}
+		if _, err := d.ReadFrame(); err != io.EOF {
+			t.Errorf("%s: ReadFrame() at the end = %v, want io.EOF", tt.name, err)
+		}
+	}
+}
+
+func TestReadFrameTruncated(t *testing.T) {
+	data := record(abs(ABS_X, 1), syn(SYN_REPORT), abs(ABS_Y, 2))
+	d := NewDecoder(bytes.NewReader(data[:len(data)-3]))
+
+	if _, err := d.ReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.ReadFrame()
+	if err == nil || err == io.EOF {
+		t.Errorf("ReadFrame() on a cut off event = %v, want a truncation error", err)
+	}
+}
+
+func TestReadFrameEOF(t *testing.T) {
+	// A frame the stream ends in the middle of is not returned.
+	d := NewDecoder(bytes.NewReader(record(abs(ABS_X, 1), abs(ABS_Y, 2))))
+	if frame, err := d.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame() = %v, %v, want io.EOF", frame, err)
+	}
+
+	d = NewDecoder(bytes.NewReader(nil))
+	if _, err := d.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame() on an empty stream = %v, want io.EOF", err)
+	}
+}
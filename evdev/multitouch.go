@@ -0,0 +1,104 @@
+package evdev
+
+import "sort"
+
+// Contact is one finger on a type B multitouch device.
+type Contact struct {
+	ID       int32 // ABS_MT_TRACKING_ID
+	X        int32
+	Y        int32
+	Pressure int32
+	Began    bool // the finger landed in the last frame
+	Moved    bool // the position changed in the last frame
+	Ended    bool // the finger lifted in the last frame
+}
+
+// MultiTouch follows the ABS_MT_SLOT / ABS_MT_TRACKING_ID protocol across
+// frames. Slots keep their last values, as the kernel only sends changes.
+type MultiTouch struct {
+	slot  int32
+	slots map[int32]*mtSlot
+}
+
+type mtSlot struct {
+	Contact
+	changed bool
+	lifted  *Contact // the contact a new tracking ID replaced this frame
+}
+
+func NewMultiTouch() *MultiTouch {
+	return &MultiTouch{slots: map[int32]*mtSlot{}}
+}
+
+// Update applies one frame from Decoder.ReadFrame and returns the contacts
+// that changed in it, ordered by slot. A contact replaced by a new one in
+// the same slot is returned Ended just before it.
+func (m *MultiTouch) Update(frame []Event) []Contact {
+	for _, ev := range frame {
+		if ev.Type != EV_ABS {
+			continue
+		}
+		if ev.Code == ABS_MT_SLOT {
+			m.slot = ev.Value
+			continue
+		}
+
+		s, ok := m.slots[m.slot]
+		if !ok {
+			s = &mtSlot{Contact: Contact{ID: -1}}
+			m.slots[m.slot] = s
+		}
+		switch ev.Code {
+		case ABS_MT_TRACKING_ID:
+			switch {
+			case ev.Value == -1:
+				s.Ended = s.ID != -1
+			case ev.Value == s.ID && !s.Ended:
+			case s.ID == -1:
+				s.ID, s.Began = ev.Value, true
+			default:
+				// A new finger in a slot still holding one, lifted earlier
+				// in the frame or never, as after SYN_DROPPED. End the
+				// old contact before the new one begins.
+				lifted := s.Contact
+				lifted.Ended = true
+				s.lifted = &lifted
+				s.Contact = Contact{ID: ev.Value, X: s.X, Y: s.Y,
+					Pressure: s.Pressure, Began: true}
+			}
+		case ABS_MT_POSITION_X:
+			s.X, s.Moved = ev.Value, true
+		case ABS_MT_POSITION_Y:
+			s.Y, s.Moved = ev.Value, true
+		case ABS_MT_PRESSURE:
+			s.Pressure = ev.Value
+		default:
+			continue
+		}
+		s.changed = true
+	}
+
+	slots := make([]int32, 0, len(m.slots))
+	for slot := range m.slots {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	var contacts []Contact
+	for _, slot := range slots {
+		s := m.slots[slot]
+		if s.lifted != nil {
+			contacts = append(contacts, *s.lifted)
+		}
+		if s.changed && s.ID != -1 {
+			contacts = append(contacts, s.Contact)
+		}
+		if s.Ended {
+			s.ID = -1
+		}
+		s.changed, s.Began, s.Moved, s.Ended = false, false, false, false
+		s.lifted = nil
+	}
+
+	return contacts
+}
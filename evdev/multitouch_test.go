@@ -0,0 +1,142 @@
+package evdev
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMultiTouch(t *testing.T) {
+	// Two fingers land in slots 0 and 1, the first moves, the second lifts
+	// and lands again as a new contact, then both lift together.
+	frames := []struct {
+		name   string
+		events []Event
+		want   []Contact
+	}{
+		{
+			"first finger",
+			[]Event{
+				abs(ABS_MT_TRACKING_ID, 10), abs(ABS_MT_POSITION_X, 100),
+				abs(ABS_MT_POSITION_Y, 200), abs(ABS_MT_PRESSURE, 30),
+			},
+			[]Contact{{ID: 10, X: 100, Y: 200, Pressure: 30, Began: true, Moved: true}},
+		},
+		{
+			"second finger",
+			[]Event{
+				abs(ABS_MT_SLOT, 1), abs(ABS_MT_TRACKING_ID, 11),
+				abs(ABS_MT_POSITION_X, 500), abs(ABS_MT_POSITION_Y, 600),
+			},
+			[]Contact{{ID: 11, X: 500, Y: 600, Began: true, Moved: true}},
+		},
+		{
+			// Slot 0 only sends Y, X keeps its last value.
+			"first moves",
+			[]Event{abs(ABS_MT_SLOT, 0), abs(ABS_MT_POSITION_Y, 210)},
+			[]Contact{{ID: 10, X: 100, Y: 210, Pressure: 30, Moved: true}},
+		},
+		{
+			"pressure only",
+			[]Event{abs(ABS_MT_PRESSURE, 40)},
+			[]Contact{{ID: 10, X: 100, Y: 210, Pressure: 40}},
+		},
+		{
+			"second lifts",
+			[]Event{abs(ABS_MT_SLOT, 1), abs(ABS_MT_TRACKING_ID, -1)},
+			[]Contact{{ID: 11, X: 500, Y: 600, Ended: true}},
+		},
+		{
+			"nothing",
+			[]Event{key(BTN_TOUCH, 1)},
+			nil,
+		},
+		{
+			// A new tracking ID in the same slot is a new finger.
+			"second lands again",
+			[]Event{abs(ABS_MT_TRACKING_ID, 12), abs(ABS_MT_POSITION_X, 50)},
+			[]Contact{{ID: 12, X: 50, Y: 600, Began: true, Moved: true}},
+		},
+		{
+			"both lift",
+			[]Event{
+				abs(ABS_MT_SLOT, 0), abs(ABS_MT_TRACKING_ID, -1),
+				abs(ABS_MT_SLOT, 1), abs(ABS_MT_TRACKING_ID, -1),
+			},
+			[]Contact{
+				{ID: 10, X: 100, Y: 210, Pressure: 40, Ended: true},
+				{ID: 12, X: 50, Y: 600, Ended: true},
+			},
+		},
+		{
+			// Lifting an empty slot again reports nothing.
+			"lift again",
+			[]Event{abs(ABS_MT_TRACKING_ID, -1)},
+			nil,
+		},
+		{
+			"slot switch only",
+			[]Event{abs(ABS_MT_SLOT, 0)},
+			nil,
+		},
+	}
+
+	mt := NewMultiTouch()
+	for _, f := range frames {
+		got := mt.Update(f.events)
+		if !reflect.DeepEqual(got, f.want) {
+			t.Errorf("%s: Update() = %+v, want %+v", f.name, got, f.want)
+		}
+	}
+}
+
+func TestMultiTouchReplaced(t *testing.T) {
+	// Slot 0 holds finger 10 at (100, 200), then a frame replaces it.
+	down := []Event{abs(ABS_MT_TRACKING_ID, 10), abs(ABS_MT_POSITION_X, 100),
+		abs(ABS_MT_POSITION_Y, 200)}
+
+	tests := []struct {
+		name   string
+		events []Event
+		want   []Contact
+	}{
+		{
+			// Allowed after SYN_DROPPED or from a lossy driver.
+			"new id without a lift",
+			[]Event{abs(ABS_MT_TRACKING_ID, 11), abs(ABS_MT_POSITION_X, 300)},
+			[]Contact{
+				{ID: 10, X: 100, Y: 200, Ended: true},
+				{ID: 11, X: 300, Y: 200, Began: true, Moved: true},
+			},
+		},
+		{
+			"lift and new id in one frame",
+			[]Event{abs(ABS_MT_TRACKING_ID, -1), abs(ABS_MT_TRACKING_ID, 11),
+				abs(ABS_MT_POSITION_Y, 400)},
+			[]Contact{
+				{ID: 10, X: 100, Y: 200, Ended: true},
+				{ID: 11, X: 100, Y: 400, Began: true, Moved: true},
+			},
+		},
+		{
+			"same id again",
+			[]Event{abs(ABS_MT_TRACKING_ID, 10)},
+			[]Contact{{ID: 10, X: 100, Y: 200}},
+		},
+	}
+
+	for _, tt := range tests {
+		mt := NewMultiTouch()
+		mt.Update(down)
+		if got := mt.Update(tt.events); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Update() = %+v, want %+v", tt.name, got, tt.want)
+		}
+
+		// The new contact lives on, the old one is gone.
+		lift := []Event{abs(ABS_MT_TRACKING_ID, -1)}
+		got := mt.Update(lift)
+		if len(got) != 1 || !got[0].Ended || got[0].ID != tt.want[len(tt.want)-1].ID {
+			t.Errorf("%s: lifting afterwards = %+v, want only the new contact ended",
+				tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package fluid
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// initBloomFramebuffers allocates the bloom target at BLOOM_RESOLUTION and
+// the pyramid the blur goes down and back up, halving once per
+// BLOOM_ITERATIONS, and frees the previous ones of fbos. Nothing is
+// allocated while BLOOM is off.
+func (s *Simulator) initBloomFramebuffers(fbos *framebuffers) {
+	if fbos.bloom != nil {
+		fbos.bloom.Delete()
+	}
+	for _, fbo := range fbos.bloomFramebuffers {
+		fbo.Delete()
+	}
+	fbos.bloom, fbos.bloomFramebuffers = nil, nil
+	if !s.Config.BLOOM {
+		return
+	}
+
+	resX, resY := getResolution(s.Config.BLOOM_RESOLUTION, s.width, s.height)
+	texType := uint32(gl.HALF_FLOAT)
+	rgbaInt, rgba := uint32(gl.RGBA16F), uint32(gl.RGBA)
+
+	fbos.bloom = createFBO(resX, resY, rgbaInt, rgba, texType, gl.LINEAR)
+	for i := 0; i < bloomLevels(resX, resY, s.Config.BLOOM_ITERATIONS); i++ {
+		w, h := resX>>uint(i+1), resY>>uint(i+1)
+		fbos.bloomFramebuffers = append(fbos.bloomFramebuffers,
+			createFBO(w, h, rgbaInt, rgba, texType, gl.LINEAR))
+	}
+}
+
+// bloomLevels returns how many times a w x h bloom target can be halved,
+// up to iterations, before a side would drop below 2 texels.
+func bloomLevels(w, h, iterations int) int {
+	levels := 0
+	for levels < iterations && w>>uint(levels+1) >= 2 && h>>uint(levels+1) >= 2 {
+		levels++
+	}
+	return levels
+}
+
+// applyBloom renders the glow of the bright parts of source into
+// destination: a prefilter keeping what is above BLOOM_THRESHOLD, a blur
+// down the pyramid, adding each level back on the way up, and a final pass
+// scaling by BLOOM_INTENSITY.
+func (s *Simulator) applyBloom(source, destination *Framebuffer) {
+	programs, pyramid := s.programs, s.fbos.bloomFramebuffers
+	if len(pyramid) < 2 {
+		return
+	}
+
+	last := destination
+
+	gl.Disable(gl.BLEND)
+	programs.bloomPrefilter.Use()
+	knee := s.Config.BLOOM_THRESHOLD*s.Config.BLOOM_SOFT_KNEE + 0.0001
+	programs.bloomPrefilter.SetVec3("curve", mgl.Vec3{
+		s.Config.BLOOM_THRESHOLD - knee, knee * 2, 0.25 / knee})
+	programs.bloomPrefilter.SetFloat("threshold", s.Config.BLOOM_THRESHOLD)
+	programs.bloomPrefilter.SetInt("uTexture", int32(source.attach(0)))
+	s.blit(last)
+
+	programs.bloomBlur.Use()
+	for _, dest := range pyramid {
+		programs.bloomBlur.SetVec2("texelSize", mgl.Vec2{last.texelSizeX, last.texelSizeY})
+		programs.bloomBlur.SetInt("uTexture", int32(last.attach(0)))
+		s.blit(dest)
+		last = dest
+	}
+
+	gl.BlendFunc(gl.ONE, gl.ONE)
+	gl.Enable(gl.BLEND)
+	for i := len(pyramid) - 2; i >= 0; i-- {
+		base := pyramid[i]
+		programs.bloomBlur.SetVec2("texelSize", mgl.Vec2{last.texelSizeX, last.texelSizeY})
+		programs.bloomBlur.SetInt("uTexture", int32(last.attach(0)))
+		s.blit(base)
+		last = base
+	}
+
+	gl.Disable(gl.BLEND)
+	programs.bloomFinal.Use()
+	programs.bloomFinal.SetVec2("texelSize", mgl.Vec2{last.texelSizeX, last.texelSizeY})
+	programs.bloomFinal.SetInt("uTexture", int32(last.attach(0)))
+	programs.bloomFinal.SetFloat("intensity", s.Config.BLOOM_INTENSITY)
+	s.blit(destination)
+}
@@ -0,0 +1,60 @@
+package fluid
+
+import (
+	"math"
+	"math/rand"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// generateColor returns a random fully saturated hue, dimmed since splats
+// add up quickly in the dye.
+func generateColor() mgl.Vec3 {
+	return hsvToRGB(rand.Float32(), 1.0, 1.0).Mul(0.15)
+}
+
+// hsvToRGB converts a colour with hue, saturation and value in [0, 1].
+func hsvToRGB(h, s, v float32) mgl.Vec3 {
+	i := math.Floor(float64(h * 6))
+	f := h*6 - float32(i)
+	p := v * (1 - s)
+	q := v * (1 - f*s)
+	t := v * (1 - (1-f)*s)
+
+	switch int(i) % 6 {
+	case 0:
+		return mgl.Vec3{v, t, p}
+	case 1:
+		return mgl.Vec3{q, v, p}
+	case 2:
+		return mgl.Vec3{p, v, t}
+	case 3:
+		return mgl.Vec3{p, q, v}
+	case 4:
+		return mgl.Vec3{t, p, v}
+	default:
+		return mgl.Vec3{v, p, q}
+	}
+}
+
+// UpdateColors advances the colour timer by dt scaled by
+// COLOR_UPDATE_SPEED, giving every pointer a new colour each time it wraps.
+// Without COLORFUL pointers keep the colour they were created with.
+func (s *Simulator) UpdateColors(dt float32) {
+	s.input.updateColors(dt)
+}
+
+func (in *pointerInput) updateColors(dt float32) {
+	c := in.backend.config()
+	if !c.COLORFUL {
+		return
+	}
+
+	in.colorUpdateTimer += dt * float32(c.COLOR_UPDATE_SPEED)
+	if in.colorUpdateTimer >= 1 {
+		in.colorUpdateTimer -= float32(math.Floor(float64(in.colorUpdateTimer)))
+		for _, pointer := range in.pointers {
+			pointer.color = generateColor()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package fluid
+
+import (
+	"math/rand"
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+func TestHSVToRGB(t *testing.T) {
+	tests := []struct {
+		h, s, v float32
+		want    mgl.Vec3
+	}{
+		// The six sector boundaries and back round to red.
+		{0, 1, 1, mgl.Vec3{1, 0, 0}},
+		{1.0 / 6, 1, 1, mgl.Vec3{1, 1, 0}},
+		{2.0 / 6, 1, 1, mgl.Vec3{0, 1, 0}},
+		{3.0 / 6, 1, 1, mgl.Vec3{0, 1, 1}},
+		{4.0 / 6, 1, 1, mgl.Vec3{0, 0, 1}},
+		{5.0 / 6, 1, 1, mgl.Vec3{1, 0, 1}},
+		{1, 1, 1, mgl.Vec3{1, 0, 0}},
+		// Halfway through a sector.
+		{1.0 / 12, 1, 1, mgl.Vec3{1, 0.5, 0}},
+		{11.0 / 12, 1, 1, mgl.Vec3{1, 0, 0.5}},
+		// Saturation and value.
+		{0.3, 0, 0.5, mgl.Vec3{0.5, 0.5, 0.5}},
+		{0, 0.5, 1, mgl.Vec3{1, 0.5, 0.5}},
+		{2.0 / 6, 1, 0.25, mgl.Vec3{0, 0.25, 0}},
+		{0.7, 1, 0, mgl.Vec3{0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := hsvToRGB(tt.h, tt.s, tt.v); !got.ApproxEqualThreshold(tt.want, 1e-5) {
+			t.Errorf("hsvToRGB(%g, %g, %g) = %v, want %v", tt.h, tt.s, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateColors(t *testing.T) {
+	tests := []struct {
+		name     string
+		colorful bool
+		speed    int
+		dts      []float32
+		changes  []bool // whether the colours changed after each dt
+	}{
+		{"off", false, 10, []float32{0.5, 0.5, 1}, []bool{false, false, false}},
+		{"no speed", true, 0, []float32{0.5, 0.5, 1}, []bool{false, false, false}},
+		// The timer wraps at 1, keeping what is left over.
+		{"speed 4", true, 4, []float32{0.125, 0.1875, 0.125, 0.0625},
+			[]bool{false, true, false, true}},
+		// Several wraps in one frame change the colours once, and leave
+		// the fraction.
+		{"long frame", true, 4, []float32{0.875, 0.0625, 0.0625},
+			[]bool{true, false, true}},
+	}
+
+	for _, tt := range tests {
+		rand.Seed(1)
+		sink := newSplatSink(100, 100)
+		sink.cfg.COLORFUL = tt.colorful
+		sink.cfg.COLOR_UPDATE_SPEED = tt.speed
+		in := newPointerInput(sink)
+		pointers := []*Pointer{in.pointerByID(0), in.pointerByID(1)}
+
+		for i, dt := range tt.dts {
+			before := []mgl.Vec3{pointers[0].color, pointers[1].color}
+			in.updateColors(dt)
+			for j, pointer := range pointers {
+				if changed := pointer.color != before[j]; changed != tt.changes[i] {
+					t.Errorf("%s: after dt %d pointer %d changed colour %v, want %v",
+						tt.name, i, j, changed, tt.changes[i])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package fluid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Config holds the simulation parameters. Field names are kept the same as
+// the web original so settings can be carried over directly.
+type Config struct {
+	SIM_RESOLUTION       int
+	DYE_RESOLUTION       int
+	CAPTURE_RESOLUTION   int
+	DENSITY_DISSIPATION  float32
+	VELOCITY_DISSIPATION float32
+	PRESSURE             float32
+	PRESSURE_ITERATIONS  int
+	CURL                 float32
+	SPLAT_RADIUS         float32
+	SPLAT_FORCE          float32
+	SHADING              bool
+	COLORFUL             bool
+	COLOR_UPDATE_SPEED   int
+	PAUSED               bool
+	BACK_COLOR           mgl.Vec3
+	TRANSPARENT          bool
+	BLOOM                bool
+	BLOOM_ITERATIONS     int
+	BLOOM_RESOLUTION     int
+	BLOOM_INTENSITY      float32
+	BLOOM_THRESHOLD      float32
+	BLOOM_SOFT_KNEE      float32
+	SUNRAYS              bool
+	SUNRAYS_RESOLUTION   int
+	SUNRAYS_WEIGHT       float32
+
+	// Fast strokes are filled in with splats STROKE_SPACING splat radii
+	// apart, 0 splats only at the latest sample. STROKE_SPLINE curves them
+	// through the previous samples instead of joining them with lines.
+	STROKE_SPACING float32
+	STROKE_SPLINE  bool
+
+	// Touch pressure scales splats of devices that report it. Each amount
+	// is how far a full or feather light press moves away from the plain
+	// splat, the curve an exponent applied to the pressure first.
+	TOUCH_PRESSURE        bool
+	TOUCH_PRESSURE_CURVE  float32
+	TOUCH_PRESSURE_RADIUS float32
+	TOUCH_PRESSURE_FORCE  float32
+	TOUCH_PRESSURE_DYE    float32
+}
+
+// DefaultConfig returns the parameters the desktop demo is tuned for.
+func DefaultConfig() Config {
+	return Config{
+		SIM_RESOLUTION:       256, //512,
+		DYE_RESOLUTION:       1024,
+		CAPTURE_RESOLUTION:   512,
+		DENSITY_DISSIPATION:  1.0, //1.0,
+		VELOCITY_DISSIPATION: 0.5, //0.0
+		PRESSURE:             0.8,
+		PRESSURE_ITERATIONS:  50, //20,
+		CURL:                 30.0,
+		SPLAT_RADIUS:         0.50, //0.85,
+		SPLAT_FORCE:          7000, //6000
+		SHADING:              true,
+		COLORFUL:             true,
+		COLOR_UPDATE_SPEED:   10,
+		PAUSED:               false,
+		BACK_COLOR:           mgl.Vec3{0, 0, 0},
+		TRANSPARENT:          false,
+		BLOOM:                true,
+		BLOOM_ITERATIONS:     8,
+		BLOOM_RESOLUTION:     256,
+		BLOOM_INTENSITY:      0.8,
+		BLOOM_THRESHOLD:      0.6,
+		BLOOM_SOFT_KNEE:      0.7,
+		SUNRAYS:              true,
+		SUNRAYS_RESOLUTION:   196,
+		SUNRAYS_WEIGHT:       1.0,
+
+		STROKE_SPACING: 0.5,
+		STROKE_SPLINE:  true,
+
+		TOUCH_PRESSURE:        false,
+		TOUCH_PRESSURE_CURVE:  1.0,
+		TOUCH_PRESSURE_RADIUS: 0.5,
+		TOUCH_PRESSURE_FORCE:  0.5,
+		TOUCH_PRESSURE_DYE:    0.5,
+	}
+}
+
+// LoadConfig reads a JSON or TOML file, picked by its extension, on top of
+// DefaultConfig. Fields missing from the file keep their default value.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := config.decode(path, data); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return config, nil
+}
+
+// decode overrides the fields present in data. The format is picked from the
+// extension of name.
+func (c *Config) decode(name string, data []byte) error {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(c)
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown config field %s", undecoded[0])
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown config format %q, want .json or .toml",
+		filepath.Ext(name))
+}
+
+// Save writes every field to a JSON or TOML file, picked by its extension,
+// so the run can be reproduced with LoadConfig.
+func (c Config) Save(path string) error {
+	var buf bytes.Buffer
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data, err := json.MarshalIndent(c, "", "\t")
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	case ".toml":
+		if err := toml.NewEncoder(&buf).Encode(c); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown config format %q, want .json or .toml",
+			filepath.Ext(path))
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// ConfigFields returns the names of every Config field in declaration order.
+func ConfigFields() []string {
+	t := reflect.TypeOf(Config{})
+	names := make([]string, t.NumField())
+	for i := range names {
+		names[i] = t.Field(i).Name
+	}
+
+	return names
+}
+
+func (c *Config) field(name string) (reflect.Value, error) {
+	v := reflect.ValueOf(c).Elem()
+	field := v.FieldByNameFunc(func(field string) bool {
+		return strings.EqualFold(field, name)
+	})
+	if !field.IsValid() {
+		return field, fmt.Errorf("unknown config field %q", name)
+	}
+
+	return field, nil
+}
+
+// Set parses value into the field called name, ignoring case. BACK_COLOR
+// takes three comma separated components.
+func (c *Config) Set(name, value string) error {
+	field, err := c.field(name)
+	if err != nil {
+		return err
+	}
+
+	value = strings.TrimSpace(value)
+	switch field.Interface().(type) {
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", name, value)
+		}
+		field.SetInt(int64(n))
+	case float32:
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", name, value)
+		}
+		field.SetFloat(f)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", name, value)
+		}
+		field.SetBool(b)
+	case mgl.Vec3:
+		parts := strings.Split(value, ",")
+		if len(parts) != 3 {
+			return fmt.Errorf("%s: %q is not three comma separated numbers",
+				name, value)
+		}
+		var vec mgl.Vec3
+		for i, part := range parts {
+			f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a number", name, part)
+			}
+			vec[i] = float32(f)
+		}
+		field.Set(reflect.ValueOf(vec))
+	}
+
+	return nil
+}
+
+// Get formats the field called name the way Set parses it.
+func (c *Config) Get(name string) (string, error) {
+	field, err := c.field(name)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := field.Interface().(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case mgl.Vec3:
+		return fmt.Sprintf("%s,%s,%s",
+			strconv.FormatFloat(float64(v[0]), 'g', -1, 32),
+			strconv.FormatFloat(float64(v[1]), 'g', -1, 32),
+			strconv.FormatFloat(float64(v[2]), 'g', -1, 32)), nil
+	}
+	return fmt.Sprint(field.Interface()), nil
+}
@@ -0,0 +1,162 @@
+package fluid
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// writeFile writes data to name in a temporary directory and returns its
+// path.
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	// The same settings in both formats, the rest left at their defaults.
+	want := DefaultConfig()
+	want.CURL = 12.5
+	want.SIM_RESOLUTION = 64
+	want.SHADING = false
+	want.BACK_COLOR = mgl.Vec3{0.1, 0.2, 0.3}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"config.json", `{"CURL": 12.5, "SIM_RESOLUTION": 64, "SHADING": false,
+			"BACK_COLOR": [0.1, 0.2, 0.3]}`},
+		{"config.toml", "CURL = 12.5\nSIM_RESOLUTION = 64\nSHADING = false\n" +
+			"BACK_COLOR = [0.1, 0.2, 0.3]\n"},
+		{"CONFIG.TOML", "CURL = 12.5\nSIM_RESOLUTION = 64\nSHADING = false\n" +
+			"BACK_COLOR = [0.1, 0.2, 0.3]\n"},
+	}
+	for _, tt := range tests {
+		got, err := LoadConfig(writeFile(t, tt.name, tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if got != want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string // in the error
+	}{
+		{"unknown.json", `{"CURL": 1, "CURLS": 2}`, "CURLS"},
+		{"unknown.toml", "CURL = 1\nCURLS = 2\n", "CURLS"},
+		{"type.json", `{"CURL": "lots"}`, "CURL"},
+		{"type.toml", `SIM_RESOLUTION = "high"`, "SIM_RESOLUTION"},
+		{"syntax.json", `{"CURL": }`, "syntax.json"},
+		{"config.yaml", "CURL: 1\n", ".yaml"},
+	}
+	for _, tt := range tests {
+		_, err := LoadConfig(writeFile(t, tt.name, tt.data))
+		if err == nil {
+			t.Errorf("%s: LoadConfig succeeded", tt.name)
+		} else if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %q does not mention %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("missing file: error %v, want not exist", err)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	config := DefaultConfig()
+	config.CURL = 0.1
+	config.SPLAT_FORCE = 1234.5
+	config.PRESSURE_ITERATIONS = 7
+	config.BLOOM = false
+	config.BACK_COLOR = mgl.Vec3{1, 0.5, 0.25}
+	config.TOUCH_PRESSURE_CURVE = 2.2
+
+	for _, name := range []string{"saved.json", "saved.toml"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := config.Save(path); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got != config {
+			t.Errorf("%s: loaded %+v, saved %+v", name, got, config)
+		}
+	}
+
+	if err := config.Save(filepath.Join(t.TempDir(), "saved.ini")); err == nil {
+		t.Error("Save wrote an .ini file")
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        string // from Get, empty for a Set error
+	}{
+		{"CURL", "12.5", "12.5"},
+		{"curl", " 3 ", "3"},
+		{"Sim_Resolution", "64", "64"},
+		{"SIM_RESOLUTION", "64.5", ""},
+		{"SHADING", "false", "false"},
+		{"SHADING", "0", "false"},
+		{"SHADING", "maybe", ""},
+		{"BACK_COLOR", "0.1, 0.2,0.3", "0.1,0.2,0.3"},
+		{"BACK_COLOR", "0.1,0.2", ""},
+		{"BACK_COLOR", "0.1,red,0.3", ""},
+		{"SPLAT_FORCE", "lots", ""},
+		{"NOT_A_FIELD", "1", ""},
+	}
+	for _, tt := range tests {
+		config := DefaultConfig()
+		err := config.Set(tt.name, tt.value)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Set(%q, %q) succeeded", tt.name, tt.value)
+			}
+			if config != DefaultConfig() {
+				t.Errorf("Set(%q, %q) failed but changed the config", tt.name, tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Set(%q, %q): %v", tt.name, tt.value, err)
+			continue
+		}
+		if got, err := config.Get(tt.name); err != nil || got != tt.want {
+			t.Errorf("Get(%q) after Set(%q) = %q, %v, want %q",
+				tt.name, tt.value, got, err, tt.want)
+		}
+	}
+
+	// Every field formats in a way Set parses back.
+	config := DefaultConfig()
+	for _, name := range ConfigFields() {
+		value, err := config.Get(name)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", name, err)
+		}
+		var parsed Config
+		if err := parsed.Set(name, value); err != nil {
+			t.Errorf("Set(%q, Get(%[1]q) = %q): %v", name, value, err)
+		}
+	}
+	if _, err := config.Get("NOT_A_FIELD"); err == nil {
+		t.Error("Get found NOT_A_FIELD")
+	}
+}
@@ -0,0 +1,516 @@
+package fluid
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"sync"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// CPUSolver runs the same passes as Simulator.Step using float32 slices in
+// place of textures. It needs no GL context, so it can run on machines
+// without a GPU and serve as a reference for the shaders in glsl.go.
+//
+// Fields are stored row by row starting at the bottom, matching texture
+// coordinates, and are sampled with clamp to edge wrapping like the GL
+// framebuffers.
+type CPUSolver struct {
+	Config Config
+
+	width      int
+	height     int
+	dye        *doubleField
+	velocity   *doubleField
+	divergence *field
+	curl       *field
+	pressure   *doubleField
+	input      *pointerInput
+}
+
+// NewCPUSolver allocates the fields for a width x height canvas.
+func NewCPUSolver(width, height int, config Config) *CPUSolver {
+	s := &CPUSolver{
+		Config: config,
+		width:  width,
+		height: height,
+	}
+	s.input = newPointerInput(s)
+	s.initFields()
+
+	return s
+}
+
+func (s *CPUSolver) config() *Config {
+	return &s.Config
+}
+
+// Size returns the canvas size the solver is simulating for.
+func (s *CPUSolver) Size() (int, int) {
+	return s.width, s.height
+}
+
+// Resize changes the canvas size, resampling the dye and velocity fields
+// the same way Simulator does.
+func (s *CPUSolver) Resize(w, h int) {
+	s.width = w
+	s.height = h
+	s.initFields()
+}
+
+// Reset empties the canvas like Simulator.Reset.
+func (s *CPUSolver) Reset() {
+	if s.input.recorder != nil {
+		s.input.recorder.reset()
+	}
+	s.dye = nil
+	s.initFields()
+}
+
+// SetConfig switches to a new config, or returns the Validate error and
+// keeps the old one. The fields are reallocated when the resolution changes.
+func (s *CPUSolver) SetConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	old := s.Config
+	s.Config = config
+	if s.input.recorder != nil {
+		s.input.recorder.config(config)
+	}
+
+	if old.SIM_RESOLUTION != config.SIM_RESOLUTION ||
+		old.DYE_RESOLUTION != config.DYE_RESOLUTION {
+		s.initFields()
+	}
+
+	return nil
+}
+
+func (s *CPUSolver) initFields() {
+	simResX, simResY := getResolution(s.Config.SIM_RESOLUTION, s.width, s.height)
+	dyeResX, dyeResY := getResolution(s.Config.DYE_RESOLUTION, s.width, s.height)
+
+	if s.dye != nil {
+		s.dye = resizeDoubleField(s.dye, dyeResX, dyeResY)
+		s.velocity = resizeDoubleField(s.velocity, simResX, simResY)
+	} else {
+		s.dye = newDoubleField(dyeResX, dyeResY, 4)
+		s.velocity = newDoubleField(simResX, simResY, 2)
+	}
+
+	s.divergence = newField(simResX, simResY, 1)
+	s.curl = newField(simResX, simResY, 1)
+	s.pressure = newDoubleField(simResX, simResY, 1)
+}
+
+// Step mirrors Simulator.Step pass for pass.
+func (s *CPUSolver) Step(dt float32) {
+	if s.input.recorder != nil {
+		s.input.recorder.step(dt)
+	}
+	s.curlPass()
+	s.vorticityPass(dt)
+	s.divergencePass()
+	s.clearPass()
+	for i := 0; i < s.Config.PRESSURE_ITERATIONS; i++ {
+		s.pressurePass()
+	}
+	s.gradientSubtractPass()
+	s.advectionPass(s.velocity, dt, s.Config.VELOCITY_DISSIPATION)
+	s.advectionPass(s.dye, dt, s.Config.DENSITY_DISSIPATION)
+}
+
+// curlShader
+func (s *CPUSolver) curlPass() {
+	velocity, curl := s.velocity.read(), s.curl
+	forEachRow(curl.height, func(y int) {
+		for x := 0; x < curl.width; x++ {
+			L := velocity.at(x-1, y, 1)
+			R := velocity.at(x+1, y, 1)
+			T := velocity.at(x, y+1, 0)
+			B := velocity.at(x, y-1, 0)
+			vorticity := R - L - T + B
+			curl.set(x, y, 0, 0.5*vorticity)
+		}
+	})
+}
+
+// vorticityShader
+func (s *CPUSolver) vorticityPass(dt float32) {
+	velocity, curl, out := s.velocity.read(), s.curl, s.velocity.write()
+	forEachRow(out.height, func(y int) {
+		for x := 0; x < out.width; x++ {
+			L := curl.at(x-1, y, 0)
+			R := curl.at(x+1, y, 0)
+			T := curl.at(x, y+1, 0)
+			B := curl.at(x, y-1, 0)
+			C := curl.at(x, y, 0)
+
+			forceX := 0.5 * (abs(T) - abs(B))
+			forceY := 0.5 * (abs(R) - abs(L))
+			length := float32(math.Sqrt(float64(forceX*forceX + forceY*forceY)))
+			forceX /= length + 0.0001
+			forceY /= length + 0.0001
+			forceX *= s.Config.CURL * C
+			forceY *= s.Config.CURL * C
+			forceY *= -1.0
+
+			vx := velocity.at(x, y, 0) + forceX*dt
+			vy := velocity.at(x, y, 1) + forceY*dt
+			out.set(x, y, 0, clamp(vx, -1000.0, 1000.0))
+			out.set(x, y, 1, clamp(vy, -1000.0, 1000.0))
+		}
+	})
+	s.velocity.swap()
+}
+
+// divergenceShader
+func (s *CPUSolver) divergencePass() {
+	velocity, divergence := s.velocity.read(), s.divergence
+	forEachRow(divergence.height, func(y int) {
+		for x := 0; x < divergence.width; x++ {
+			L := velocity.at(x-1, y, 0)
+			R := velocity.at(x+1, y, 0)
+			T := velocity.at(x, y+1, 1)
+			B := velocity.at(x, y-1, 1)
+
+			if x == 0 {
+				L = -velocity.at(x, y, 0)
+			}
+			if x == divergence.width-1 {
+				R = -velocity.at(x, y, 0)
+			}
+			if y == divergence.height-1 {
+				T = -velocity.at(x, y, 1)
+			}
+			if y == 0 {
+				B = -velocity.at(x, y, 1)
+			}
+
+			divergence.set(x, y, 0, 0.5*(R-L+T-B))
+		}
+	})
+}
+
+// clearShader
+func (s *CPUSolver) clearPass() {
+	pressure, out := s.pressure.read(), s.pressure.write()
+	for i, p := range pressure.data {
+		out.data[i] = s.Config.PRESSURE * p
+	}
+	s.pressure.swap()
+}
+
+// pressureShader, one Jacobi iteration.
+func (s *CPUSolver) pressurePass() {
+	pressure, divergence, out := s.pressure.read(), s.divergence, s.pressure.write()
+	forEachRow(out.height, func(y int) {
+		for x := 0; x < out.width; x++ {
+			L := pressure.at(x-1, y, 0)
+			R := pressure.at(x+1, y, 0)
+			T := pressure.at(x, y+1, 0)
+			B := pressure.at(x, y-1, 0)
+			div := divergence.at(x, y, 0)
+			out.set(x, y, 0, (L+R+B+T-div)*0.25)
+		}
+	})
+	s.pressure.swap()
+}
+
+// gradientSubtractShader
+func (s *CPUSolver) gradientSubtractPass() {
+	pressure, velocity, out := s.pressure.read(), s.velocity.read(), s.velocity.write()
+	forEachRow(out.height, func(y int) {
+		for x := 0; x < out.width; x++ {
+			L := pressure.at(x-1, y, 0)
+			R := pressure.at(x+1, y, 0)
+			T := pressure.at(x, y+1, 0)
+			B := pressure.at(x, y-1, 0)
+			out.set(x, y, 0, velocity.at(x, y, 0)-(R-L))
+			out.set(x, y, 1, velocity.at(x, y, 1)-(T-B))
+		}
+	})
+	s.velocity.swap()
+}
+
+// advectionShader without MANUAL_FILTERING. The velocity texel size is used
+// for the back trace whatever the resolution of target, like in Step.
+func (s *CPUSolver) advectionPass(target *doubleField, dt, dissipation float32) {
+	velocity, source, out := s.velocity.read(), target.read(), target.write()
+	texelSizeX := 1.0 / float32(velocity.width)
+	texelSizeY := 1.0 / float32(velocity.height)
+	decay := 1.0 + dissipation*dt
+	forEachRow(out.height, func(y int) {
+		v := (float32(y) + 0.5) / float32(out.height)
+		for x := 0; x < out.width; x++ {
+			u := (float32(x) + 0.5) / float32(out.width)
+			coordX := u - dt*velocity.sample(u, v, 0)*texelSizeX
+			coordY := v - dt*velocity.sample(u, v, 1)*texelSizeY
+			for c := 0; c < out.channels; c++ {
+				out.set(x, y, c, source.sample(coordX, coordY, c)/decay)
+			}
+		}
+	})
+	target.swap()
+}
+
+// Snapshot draws the dye through displayShader over an opaque black
+// background, like Simulator.Render with only SHADING. BLOOM and SUNRAYS,
+// and the dithering that comes with bloom, have no CPU version and are
+// ignored, so with them on the two backends give different images.
+func (s *CPUSolver) Snapshot() *image.RGBA {
+	dye := s.dye.read()
+	texelSizeX := 1.0 / float32(s.width)
+	texelSizeY := 1.0 / float32(s.height)
+	texelLength := float32(math.Sqrt(float64(texelSizeX*texelSizeX +
+		texelSizeY*texelSizeY)))
+
+	img := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	forEachRow(s.height, func(y int) {
+		v := (float32(y) + 0.5) / float32(s.height)
+		row := img.Pix[(s.height-1-y)*img.Stride:]
+		for x := 0; x < s.width; x++ {
+			u := (float32(x) + 0.5) / float32(s.width)
+			c := dye.sampleRGB(u, v)
+
+			if s.Config.SHADING {
+				lc := dye.sampleRGB(u-texelSizeX, v)
+				rc := dye.sampleRGB(u+texelSizeX, v)
+				tc := dye.sampleRGB(u, v+texelSizeY)
+				bc := dye.sampleRGB(u, v-texelSizeY)
+
+				dx := rc.Len() - lc.Len()
+				dy := tc.Len() - bc.Len()
+
+				n := mgl.Vec3{dx, dy, texelLength}.Normalize()
+				diffuse := clamp(n[2]+0.7, 0.7, 1.0)
+				c = c.Mul(diffuse)
+			}
+
+			for i := 0; i < 3; i++ {
+				row[x*4+i] = toByte(c[i])
+			}
+			row[x*4+3] = 255
+		}
+	})
+
+	return img
+}
+
+// MultipleSplats adds n splats of random position, velocity and colour.
+func (s *CPUSolver) MultipleSplats(n int) {
+	multipleSplats(s, n)
+}
+
+// Splat mirrors splatShader on the velocity and dye fields.
+func (s *CPUSolver) Splat(x, y, dx, dy float32, col mgl.Vec3) {
+	if s.input.recorder != nil {
+		s.input.recorder.splat(x, y, dx, dy, col)
+	}
+	s.splat(x, y, dx, dy, col, s.Config.SPLAT_RADIUS/100.0)
+}
+
+func (s *CPUSolver) splat(x, y, dx, dy float32, col mgl.Vec3, radius float32) {
+	aspectRatio := float32(s.width) / float32(s.height)
+	radius = correctRadius(radius, s.width, s.height)
+
+	splatField(s.velocity, x, y, aspectRatio, radius, []float32{dx, dy})
+	splatField(s.dye, x, y, aspectRatio, radius, []float32{col[0], col[1], col[2]})
+}
+
+// PushPointerEvent queues ev for the next ApplyInputs like
+// Simulator.PushPointerEvent, and is as safe to call from any goroutine.
+func (s *CPUSolver) PushPointerEvent(ev PointerEvent) {
+	s.input.push(ev)
+}
+
+// ApplyInputs splats the queued pointer events like Simulator.ApplyInputs.
+func (s *CPUSolver) ApplyInputs() {
+	s.input.applyQueued()
+}
+
+// UpdateColors advances the pointer colours like Simulator.UpdateColors.
+func (s *CPUSolver) UpdateColors(dt float32) {
+	s.input.updateColors(dt)
+}
+
+// Record attaches r like Simulator.Record.
+func (s *CPUSolver) Record(r *Recorder) {
+	s.input.recorder = r
+	if r != nil {
+		r.config(s.Config)
+	}
+}
+
+func (s *CPUSolver) inputs() *pointerInput {
+	return s.input
+}
+
+func splatField(target *doubleField, pointX, pointY, aspectRatio,
+	radius float32, color []float32) {
+
+	base, out := target.read(), target.write()
+	forEachRow(out.height, func(y int) {
+		v := (float32(y) + 0.5) / float32(out.height)
+		for x := 0; x < out.width; x++ {
+			u := (float32(x) + 0.5) / float32(out.width)
+			px := (u - pointX) * aspectRatio
+			py := v - pointY
+			falloff := float32(math.Exp(float64(-(px*px + py*py) / radius)))
+			for c := 0; c < out.channels; c++ {
+				value := float32(1.0) // FragColor alpha
+				if c < len(color) {
+					value = base.at(x, y, c) + falloff*color[c]
+				}
+				out.set(x, y, c, value)
+			}
+		}
+	})
+	target.swap()
+}
+
+// field is the CPU counterpart of a framebuffer texture.
+type field struct {
+	width    int
+	height   int
+	channels int
+	data     []float32
+}
+
+func newField(w, h, channels int) *field {
+	return &field{w, h, channels, make([]float32, w*h*channels)}
+}
+
+// at reads texel (x, y) clamping to the edge like CLAMP_TO_EDGE.
+func (f *field) at(x, y, c int) float32 {
+	if x < 0 {
+		x = 0
+	} else if x >= f.width {
+		x = f.width - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= f.height {
+		y = f.height - 1
+	}
+	return f.data[(y*f.width+x)*f.channels+c]
+}
+
+func (f *field) set(x, y, c int, value float32) {
+	f.data[(y*f.width+x)*f.channels+c] = value
+}
+
+// sample reads the field at texture coordinate (u, v) with LINEAR filtering.
+func (f *field) sample(u, v float32, c int) float32 {
+	sx := u*float32(f.width) - 0.5
+	sy := v*float32(f.height) - 0.5
+	fx := float32(math.Floor(float64(sx)))
+	fy := float32(math.Floor(float64(sy)))
+	x, y := int(fx), int(fy)
+	tx, ty := sx-fx, sy-fy
+
+	a := f.at(x, y, c)
+	b := f.at(x+1, y, c)
+	cc := f.at(x, y+1, c)
+	d := f.at(x+1, y+1, c)
+
+	return mix(mix(a, b, tx), mix(cc, d, tx), ty)
+}
+
+func (f *field) sampleRGB(u, v float32) mgl.Vec3 {
+	return mgl.Vec3{f.sample(u, v, 0), f.sample(u, v, 1), f.sample(u, v, 2)}
+}
+
+type doubleField struct {
+	field1 *field
+	field2 *field
+}
+
+func newDoubleField(w, h, channels int) *doubleField {
+	return &doubleField{newField(w, h, channels), newField(w, h, channels)}
+}
+
+func (df *doubleField) read() *field {
+	return df.field1
+}
+
+func (df *doubleField) write() *field {
+	return df.field2
+}
+
+func (df *doubleField) swap() {
+	df.field1, df.field2 = df.field2, df.field1
+}
+
+// resizeDoubleField resamples the read side into a w x h field the way
+// resizeDoubleFBO does with copyShader.
+func resizeDoubleField(target *doubleField, w, h int) *doubleField {
+	old := target.read()
+	if old.width == w && old.height == h {
+		return target
+	}
+
+	resized := newDoubleField(w, h, old.channels)
+	out := resized.read()
+	forEachRow(h, func(y int) {
+		v := (float32(y) + 0.5) / float32(h)
+		for x := 0; x < w; x++ {
+			u := (float32(x) + 0.5) / float32(w)
+			for c := 0; c < old.channels; c++ {
+				out.set(x, y, c, old.sample(u, v, c))
+			}
+		}
+	})
+
+	return resized
+}
+
+// forEachRow runs fn for every row in [0, height) spread over the CPUs. Each
+// pass only writes its own row so the result does not depend on scheduling.
+func forEachRow(height int, fn func(y int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > height {
+		workers = height
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for y := w; y < height; y += workers {
+				fn(y)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func clamp(x, min, max float32) float32 {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+// toByte converts a colour channel the way an RGBA8 render target does.
+func toByte(x float32) uint8 {
+	return uint8(clamp(x, 0, 1)*255 + 0.5)
+}
+
+func mix(x, y, a float32) float32 {
+	return x*(1-a) + y*a
+}
@@ -0,0 +1,437 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// newTestSolver returns a solver whose fields are all size x size.
+func newTestSolver(size int) *CPUSolver {
+	config := DefaultConfig()
+	config.SIM_RESOLUTION = size
+	config.DYE_RESOLUTION = size
+	return NewCPUSolver(size, size, config)
+}
+
+// fill sets channel c of every texel of f to fn(x, y).
+func fill(f *field, c int, fn func(x, y int) float32) {
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			f.set(x, y, c, fn(x, y))
+		}
+	}
+}
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+func TestFieldBoundary(t *testing.T) {
+	f := newField(3, 2, 1)
+	fill(f, 0, func(x, y int) float32 { return float32(10*y + x) })
+
+	tests := []struct {
+		x, y int
+		want float32
+	}{
+		{0, 0, 0},
+		{2, 1, 12},
+		{-1, 0, 0},
+		{3, 0, 2},
+		{1, -5, 1},
+		{1, 2, 11},
+		{-1, 9, 10},
+	}
+	for _, tt := range tests {
+		if got := f.at(tt.x, tt.y, 0); got != tt.want {
+			t.Errorf("at(%d, %d) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	samples := []struct {
+		u, v float32
+		want float32
+	}{
+		{0.5 / 3, 0.25, 0},    // texel centre
+		{1.0 / 3, 0.25, 0.5},  // halfway between two texels
+		{0.5, 0.5, 6},         // between all four around the middle
+		{-1, 0.25, 0},         // clamped to the left edge
+		{2, 2, 12},            // clamped to the top right corner
+		{2.5 / 3, -0.5, 2},    // clamped to the bottom edge
+		{0.5 / 3, 0.75, 10.0}, // texel centre of the top row
+	}
+	for _, tt := range samples {
+		if got := f.sample(tt.u, tt.v, 0); !near(got, tt.want) {
+			t.Errorf("sample(%v, %v) = %v, want %v", tt.u, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestAdvectionPass(t *testing.T) {
+	const size, dt = 8, 0.5
+
+	tests := []struct {
+		name        string
+		vx, vy      float32 // texels per second
+		dissipation float32
+		// want is the dye at (x, y) given the source ramp.
+		want func(x, y int) float32
+	}{
+		{"still", 0, 0, 0, func(x, y int) float32 { return ramp(x, y) }},
+		{"right", 2, 0, 0, func(x, y int) float32 { return ramp(x-1, y) }},
+		{"down", 0, -2, 0, func(x, y int) float32 { return ramp(x, y+1) }},
+		{"diagonal", 2, 2, 0, func(x, y int) float32 { return ramp(x-1, y-1) }},
+		{"dissipation", 0, 0, 1, func(x, y int) float32 { return ramp(x, y) / 1.5 }},
+	}
+
+	for _, tt := range tests {
+		s := newTestSolver(size)
+		fill(s.velocity.read(), 0, func(x, y int) float32 { return tt.vx })
+		fill(s.velocity.read(), 1, func(x, y int) float32 { return tt.vy })
+		fill(s.dye.read(), 0, ramp)
+
+		s.advectionPass(s.dye, dt, tt.dissipation)
+
+		dye := s.dye.read()
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				if got, want := dye.at(x, y, 0), tt.want(x, y); !near(got, want) {
+					t.Errorf("%s: dye at (%d, %d) = %v, want %v",
+						tt.name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// ramp is a test pattern that differs in x and y, clamped like a texture.
+func ramp(x, y int) float32 {
+	x = int(clamp(float32(x), 0, 7))
+	y = int(clamp(float32(y), 0, 7))
+	return float32(x) + 10*float32(y)
+}
+
+func TestDivergencePass(t *testing.T) {
+	const size = 4
+
+	tests := []struct {
+		name   string
+		vx, vy func(x, y int) float32
+		want   [size][size]float32 // [y][x]
+	}{
+		{
+			"still",
+			func(x, y int) float32 { return 0 },
+			func(x, y int) float32 { return 0 },
+			[size][size]float32{},
+		},
+		{
+			// Uniform flow has no divergence inside, but the walls
+			// reflect it, so it piles up at the edge it flows into.
+			"uniform",
+			func(x, y int) float32 { return 1 },
+			func(x, y int) float32 { return 0 },
+			[size][size]float32{
+				{1, 0, 0, -1},
+				{1, 0, 0, -1},
+				{1, 0, 0, -1},
+				{1, 0, 0, -1},
+			},
+		},
+		{
+			"expanding",
+			func(x, y int) float32 { return float32(x) },
+			func(x, y int) float32 { return float32(y) },
+			[size][size]float32{
+				{1, 1.5, 1.5, -2},
+				{1.5, 2, 2, -1.5},
+				{1.5, 2, 2, -1.5},
+				{-2, -1.5, -1.5, -5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s := newTestSolver(size)
+		fill(s.velocity.read(), 0, tt.vx)
+		fill(s.velocity.read(), 1, tt.vy)
+
+		s.divergencePass()
+
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				got := s.divergence.at(x, y, 0)
+				if want := tt.want[y][x]; !near(got, want) {
+					t.Errorf("%s: divergence at (%d, %d) = %v, want %v",
+						tt.name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPressureSolve(t *testing.T) {
+	const size = 8
+
+	// One Jacobi iteration from zero pressure.
+	s := newTestSolver(size)
+	fill(s.divergence, 0, func(x, y int) float32 { return 1 })
+	s.pressurePass()
+	fill(s.divergence, 0, func(x, y int) float32 { return 0 })
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if got := s.pressure.read().at(x, y, 0); !near(got, -0.25) {
+				t.Fatalf("pressure at (%d, %d) = %v, want -0.25", x, y, got)
+			}
+		}
+	}
+
+	// The clear pass scales what is left by PRESSURE.
+	s.Config.PRESSURE = 0.5
+	s.clearPass()
+	if got := s.pressure.read().at(3, 3, 0); !near(got, -0.125) {
+		t.Errorf("cleared pressure = %v, want -0.125", got)
+	}
+
+	// Enough iterations solve the Poisson equation, with the clamped
+	// edges acting as walls the pressure does not flow through.
+	s = newTestSolver(size)
+	s.divergence.set(2, 2, 0, 1)
+	s.divergence.set(5, 6, 0, -1)
+	for i := 0; i < 2000; i++ {
+		s.pressurePass()
+	}
+
+	pressure := s.pressure.read()
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			p := pressure.at(x, y, 0)
+			residual := pressure.at(x-1, y, 0) + pressure.at(x+1, y, 0) +
+				pressure.at(x, y-1, 0) + pressure.at(x, y+1, 0) - 4*p -
+				s.divergence.at(x, y, 0)
+			if !near(residual, 0) {
+				t.Fatalf("residual at (%d, %d) = %v, want 0", x, y, residual)
+			}
+		}
+	}
+	if pressure.at(2, 2, 0) >= 0 || pressure.at(5, 6, 0) <= 0 {
+		t.Errorf("pressure %v at the source and %v at the sink, want a dip and a peak",
+			pressure.at(2, 2, 0), pressure.at(5, 6, 0))
+	}
+}
+
+// checkField reports every texel of channel c of f that differs from want.
+func checkField(t *testing.T, name string, f *field, c int, want func(x, y int) float32) {
+	t.Helper()
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			if got, w := f.at(x, y, c), want(x, y); !near(got, w) {
+				t.Errorf("%s: channel %d at (%d, %d) = %v, want %v", name, c, x, y, got, w)
+			}
+		}
+	}
+}
+
+func TestCurlPass(t *testing.T) {
+	const size = 4
+
+	tests := []struct {
+		name   string
+		vx, vy func(x, y int) float32
+		want   [size][size]float32 // [y][x]
+	}{
+		{
+			"still",
+			func(x, y int) float32 { return 0 },
+			func(x, y int) float32 { return 0 },
+			[size][size]float32{},
+		},
+		{
+			// Counterclockwise rotation, the clamped edges only see half
+			// of the difference.
+			"rotation",
+			func(x, y int) float32 { return -float32(y) },
+			func(x, y int) float32 { return float32(x) },
+			[size][size]float32{
+				{1, 1.5, 1.5, 1},
+				{1.5, 2, 2, 1.5},
+				{1.5, 2, 2, 1.5},
+				{1, 1.5, 1.5, 1},
+			},
+		},
+		{
+			// Faster to the right higher up turns clockwise.
+			"shear",
+			func(x, y int) float32 { return float32(y) },
+			func(x, y int) float32 { return 0 },
+			[size][size]float32{
+				{-0.5, -0.5, -0.5, -0.5},
+				{-1, -1, -1, -1},
+				{-1, -1, -1, -1},
+				{-0.5, -0.5, -0.5, -0.5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s := newTestSolver(size)
+		fill(s.velocity.read(), 0, tt.vx)
+		fill(s.velocity.read(), 1, tt.vy)
+
+		s.curlPass()
+
+		checkField(t, tt.name, s.curl, 0, func(x, y int) float32 { return tt.want[y][x] })
+	}
+}
+
+func TestVorticityPass(t *testing.T) {
+	const size, dt = 4, 0.1
+
+	// The force is normalized against its length plus 0.0001.
+	norm := func(length float32) float32 { return length / (length + 0.0001) }
+	// With the curl rising to the right, the gradient of its magnitude is 1
+	// inside and 0.5 on the clamped edges.
+	gradient := func(x int) float32 {
+		if x == 0 || x == size-1 {
+			return 0.5
+		}
+		return 1
+	}
+
+	tests := []struct {
+		name   string
+		curl   float32 // CURL
+		c      func(x, y int) float32
+		vx, vy float32 // starting velocity
+		wantVY func(x, y int) float32
+	}{
+		{"no curl", 0, func(x, y int) float32 { return float32(x) }, 3, -2,
+			func(x, y int) float32 { return -2 }},
+		{"uniform curl", 10, func(x, y int) float32 { return 5 }, 3, -2,
+			func(x, y int) float32 { return -2 }},
+		{
+			// The force points across the gradient, down where the
+			// curl is positive.
+			"rising curl", 10, func(x, y int) float32 { return float32(x) }, 0, 1,
+			func(x, y int) float32 { return 1 - 10*float32(x)*dt*norm(gradient(x)) },
+		},
+		{"clamped", 1e6, func(x, y int) float32 { return float32(x) }, 0, 0,
+			func(x, y int) float32 {
+				if x == 0 {
+					return 0
+				}
+				return -1000
+			}},
+	}
+
+	for _, tt := range tests {
+		s := newTestSolver(size)
+		s.Config.CURL = tt.curl
+		fill(s.curl, 0, tt.c)
+		fill(s.velocity.read(), 0, func(x, y int) float32 { return tt.vx })
+		fill(s.velocity.read(), 1, func(x, y int) float32 { return tt.vy })
+
+		s.vorticityPass(dt)
+
+		// The gradient is along x only, so vx never changes.
+		checkField(t, tt.name, s.velocity.read(), 0, func(x, y int) float32 { return tt.vx })
+		checkField(t, tt.name, s.velocity.read(), 1, tt.wantVY)
+	}
+}
+
+func TestGradientSubtractPass(t *testing.T) {
+	const size = 4
+
+	// The difference across a texel, half of it on the clamped edges.
+	edgeHalved := func(i int) float32 {
+		if i == 0 || i == size-1 {
+			return 1
+		}
+		return 2
+	}
+
+	tests := []struct {
+		name           string
+		pressure       func(x, y int) float32
+		wantVX, wantVY func(x, y int) float32
+	}{
+		{"uniform", func(x, y int) float32 { return 7 },
+			func(x, y int) float32 { return 1 },
+			func(x, y int) float32 { return -1 }},
+		{"rising right", func(x, y int) float32 { return float32(x) },
+			func(x, y int) float32 { return 1 - edgeHalved(x) },
+			func(x, y int) float32 { return -1 }},
+		{"rising up", func(x, y int) float32 { return 3 * float32(y) },
+			func(x, y int) float32 { return 1 },
+			func(x, y int) float32 { return -1 - 3*edgeHalved(y) }},
+	}
+
+	for _, tt := range tests {
+		s := newTestSolver(size)
+		fill(s.pressure.read(), 0, tt.pressure)
+		fill(s.velocity.read(), 0, func(x, y int) float32 { return 1 })
+		fill(s.velocity.read(), 1, func(x, y int) float32 { return -1 })
+
+		s.gradientSubtractPass()
+
+		checkField(t, tt.name, s.velocity.read(), 0, tt.wantVX)
+		checkField(t, tt.name, s.velocity.read(), 1, tt.wantVY)
+	}
+}
+
+func TestSplat(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		x, y          int // texel the splat is centred on
+	}{
+		{"square", 8, 8, 2, 5},
+		{"wide", 16, 8, 11, 1},
+		{"tall", 8, 16, 6, 12},
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.SIM_RESOLUTION = 8
+		config.DYE_RESOLUTION = 8
+		config.SPLAT_RADIUS = 0.5
+		s := NewCPUSolver(tt.width, tt.height, config)
+		dye := s.dye.read()
+		if dye.width != tt.width || dye.height != tt.height {
+			t.Fatalf("%s: dye is %dx%d, want %dx%d",
+				tt.name, dye.width, dye.height, tt.width, tt.height)
+		}
+		fill(dye, 2, func(x, y int) float32 { return 0.25 })
+
+		u := (float32(tt.x) + 0.5) / float32(tt.width)
+		v := (float32(tt.y) + 0.5) / float32(tt.height)
+		s.Splat(u, v, 100, -50, mgl.Vec3{1, 0.5, 0})
+
+		// Scaled by the aspect ratio x is measured in the same units as y,
+		// and the radius grows with it on wide canvases.
+		aspectRatio := float32(tt.width) / float32(tt.height)
+		radius := config.SPLAT_RADIUS / 100
+		if aspectRatio > 1 {
+			radius *= aspectRatio
+		}
+		falloff := func(x, y int) float32 {
+			px := (float32(x)+0.5)/float32(tt.width) - u
+			py := (float32(y)+0.5)/float32(tt.height) - v
+			px *= aspectRatio
+			return float32(math.Exp(float64(-(px*px + py*py) / radius)))
+		}
+
+		velocity, dye := s.velocity.read(), s.dye.read()
+		if got := dye.at(tt.x, tt.y, 0); !near(got, 1) {
+			t.Errorf("%s: red at the centre = %v, want 1", tt.name, got)
+		}
+		checkField(t, tt.name+" vx", velocity, 0, func(x, y int) float32 { return 100 * falloff(x, y) })
+		checkField(t, tt.name+" vy", velocity, 1, func(x, y int) float32 { return -50 * falloff(x, y) })
+		checkField(t, tt.name+" red", dye, 0, falloff)
+		checkField(t, tt.name+" green", dye, 1, func(x, y int) float32 { return 0.5 * falloff(x, y) })
+		checkField(t, tt.name+" blue", dye, 2, func(x, y int) float32 { return 0.25 })
+		checkField(t, tt.name+" alpha", dye, 3, func(x, y int) float32 { return 1 })
+	}
+}
@@ -0,0 +1,74 @@
+package fluid
+
+import (
+	"flag"
+	"strings"
+)
+
+// ConfigFlags registers one command line flag per Config field, named after
+// the field in lower case (-sim_resolution, -curl, -back_color, ...). The
+// values are only recorded while parsing so they can be applied on top of a
+// config file loaded afterwards.
+type ConfigFlags struct {
+	values map[string]string
+}
+
+// NewConfigFlags adds the config flags to fs, showing the DefaultConfig
+// values as defaults.
+func NewConfigFlags(fs *flag.FlagSet) *ConfigFlags {
+	f := &ConfigFlags{map[string]string{}}
+
+	defaults := DefaultConfig()
+	for _, name := range ConfigFields() {
+		value, _ := defaults.Get(name)
+		fs.Var(&configFlag{f, name, value}, strings.ToLower(name),
+			"overrides the config field "+name)
+	}
+
+	return f
+}
+
+// Apply sets every field given on the command line.
+func (f *ConfigFlags) Apply(c *Config) error {
+	for _, name := range ConfigFields() {
+		value, ok := f.values[name]
+		if !ok {
+			continue
+		}
+		if err := c.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type configFlag struct {
+	flags *ConfigFlags
+	name  string
+	value string
+}
+
+func (cf *configFlag) String() string {
+	return cf.value
+}
+
+func (cf *configFlag) Set(value string) error {
+	// Check the value parses now so mistakes are reported by flag.Parse.
+	config := DefaultConfig()
+	if err := config.Set(cf.name, value); err != nil {
+		return err
+	}
+
+	cf.value = value
+	cf.flags.values[cf.name] = value
+	return nil
+}
+
+// IsBoolFlag lets boolean fields be given as just -shading.
+func (cf *configFlag) IsBoolFlag() bool {
+	config := DefaultConfig()
+	field, _ := config.field(cf.name)
+	_, ok := field.Interface().(bool)
+	return ok
+}
@@ -0,0 +1,61 @@
+package fluid
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestConfigFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want func(c *Config)
+	}{
+		{nil, func(c *Config) {}},
+		{[]string{"-curl", "5"}, func(c *Config) { c.CURL = 5 }},
+		{[]string{"-shading=false", "-bloom_iterations=3"}, func(c *Config) {
+			c.SHADING = false
+			c.BLOOM_ITERATIONS = 3
+		}},
+		{[]string{"-transparent"}, func(c *Config) { c.TRANSPARENT = true }},
+		{[]string{"-back_color", "1,0,0.5"}, func(c *Config) { c.BACK_COLOR[0], c.BACK_COLOR[2] = 1, 0.5 }},
+		{[]string{"-curl", "5", "-curl", "6"}, func(c *Config) { c.CURL = 6 }},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags := NewConfigFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+
+		// Flags only touch the fields given, on top of whatever was
+		// loaded before.
+		config := DefaultConfig()
+		config.DYE_RESOLUTION = 99
+		want := config
+		tt.want(&want)
+		if err := flags.Apply(&config); err != nil {
+			t.Errorf("%q: Apply: %v", tt.args, err)
+		} else if config != want {
+			t.Errorf("%q: Apply gave %+v, want %+v", tt.args, config, want)
+		}
+	}
+}
+
+func TestConfigFlagErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-curl", "lots"},
+		{"-sim_resolution", "1.5"},
+		{"-back_color", "1,0"},
+		{"-shading=perhaps"},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		NewConfigFlags(fs)
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("%q parsed", args)
+		}
+	}
+}
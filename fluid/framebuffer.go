@@ -0,0 +1,347 @@
+package fluid
+
+import (
+	"bytes"
+	_ "embed"
+	"image"
+	"image/draw"
+	_ "image/png"
+	"io"
+	"log"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Create framebuffers
+type framebuffers struct {
+	dye        *doubleFramebuffer
+	velocity   *doubleFramebuffer
+	divergence *Framebuffer
+	curl       *Framebuffer
+	pressure   *doubleFramebuffer
+
+	bloom             *Framebuffer
+	bloomFramebuffers []*Framebuffer
+
+	sunrays     *Framebuffer
+	sunraysTemp *Framebuffer
+}
+
+// Framebuffer is a texture backed render target. A nil *Framebuffer refers
+// to the default framebuffer of the current context.
+type Framebuffer struct {
+	texture    uint32
+	fbo        uint32
+	width      int
+	height     int
+	texelSizeX float32
+	texelSizeY float32
+}
+
+// NewFramebuffer creates an 8 bit RGBA render target that a Simulator can
+// render into, for example to composite the fluid into a larger scene.
+func NewFramebuffer(w, h int) *Framebuffer {
+	return createFBO(w, h, gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE, gl.LINEAR)
+}
+
+// Texture returns the GL name of the texture backing the framebuffer.
+func (f *Framebuffer) Texture() uint32 {
+	return f.texture
+}
+
+// Delete frees the texture and framebuffer objects.
+func (f *Framebuffer) Delete() {
+	gl.DeleteFramebuffers(1, &f.fbo)
+	gl.DeleteTextures(1, &f.texture)
+}
+
+func (f *Framebuffer) clear() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, f.fbo)
+	gl.ClearColor(0.0, 0.0, 0.0, 0.0)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+}
+
+func (f *Framebuffer) attach(id uint32) uint32 {
+	gl.ActiveTexture(gl.TEXTURE0 + id)
+	gl.BindTexture(gl.TEXTURE_2D, f.texture)
+
+	return id
+}
+
+type doubleFramebuffer struct {
+	width      int
+	height     int
+	texelSizeX float32
+	texelSizeY float32
+	fbo1       *Framebuffer
+	fbo2       *Framebuffer
+}
+
+func (df *doubleFramebuffer) read() *Framebuffer {
+	return df.fbo1
+}
+
+func (df *doubleFramebuffer) write() *Framebuffer {
+	return df.fbo2
+}
+
+func (df *doubleFramebuffer) writeB(f *Framebuffer) {
+	df.fbo2 = f
+}
+
+func (df *doubleFramebuffer) clear() {
+	df.fbo1.clear()
+	df.fbo2.clear()
+}
+
+func (df *doubleFramebuffer) delete() {
+	df.fbo1.Delete()
+	df.fbo2.Delete()
+}
+
+func (df *doubleFramebuffer) swap() {
+	temp := df.fbo1
+	df.fbo1 = df.fbo2
+	df.fbo2 = temp
+}
+
+func (s *Simulator) initFramebuffers(fbos *framebuffers) *framebuffers {
+	simResX, simResY := getResolution(s.Config.SIM_RESOLUTION, s.width, s.height)
+	dyeResX, dyeResY := getResolution(s.Config.DYE_RESOLUTION, s.width, s.height)
+
+	texType := uint32(gl.HALF_FLOAT)
+	rgbaInt, rgba := uint32(gl.RGBA16F), uint32(gl.RGBA)
+	rgInt, rg := uint32(gl.RG16F), uint32(gl.RG)
+	rInt, r := uint32(gl.R16F), uint32(gl.RED)
+	filtering := int32(gl.LINEAR)
+
+	gl.Disable(gl.BLEND)
+
+	var dye, velocity *doubleFramebuffer
+	if fbos != nil {
+		dye = s.resizeDoubleFBO(fbos.dye, dyeResX, dyeResY, rgbaInt,
+			rgba, texType, filtering)
+		velocity = s.resizeDoubleFBO(fbos.velocity, simResX, simResY,
+			rgInt, rg, texType, filtering)
+		// These are recomputed every step, so nothing needs carrying over.
+		fbos.divergence.Delete()
+		fbos.curl.Delete()
+		fbos.pressure.delete()
+	} else {
+		dye = createDoubleFBO(dyeResX, dyeResY, rgbaInt, rgba, texType, filtering)
+		velocity = createDoubleFBO(simResX, simResY, rgInt, rg, texType, filtering)
+	}
+
+	divergence := createFBO(simResX, simResY, rInt, r, texType, gl.NEAREST)
+	curl := createFBO(simResX, simResY, rInt, r, texType, gl.NEAREST)
+	pressure := createDoubleFBO(simResX, simResY, rInt, r, texType, gl.NEAREST)
+
+	newFBOs := &framebuffers{dye: dye, velocity: velocity,
+		divergence: divergence, curl: curl, pressure: pressure}
+	if fbos != nil {
+		newFBOs.bloom, newFBOs.bloomFramebuffers = fbos.bloom, fbos.bloomFramebuffers
+		newFBOs.sunrays, newFBOs.sunraysTemp = fbos.sunrays, fbos.sunraysTemp
+	}
+	s.initBloomFramebuffers(newFBOs)
+	s.initSunraysFramebuffers(newFBOs)
+
+	return newFBOs
+}
+
+func createFBO(w, h int, internalFormat, format, texType uint32, param int32) *Framebuffer {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, param)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, param)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, int32(internalFormat), int32(w), int32(h),
+		0, format, texType, gl.Ptr(nil))
+	// 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0,
+	//      gl.TEXTURE_2D, textureColorbuffer, 0)
+
+	var fbo uint32
+	gl.GenFramebuffers(1, &fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0,
+		gl.TEXTURE_2D, texture, 0)
+	gl.Viewport(0, 0, int32(w), int32(h))
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+
+	// TODO check
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		panic(status)
+	}
+
+	log.Println("FBO text", texture)
+
+	return &Framebuffer{texture, fbo, w, h, 1.0 / float32(w), 1.0 / float32(h)}
+}
+
+func createDoubleFBO(w, h int, internalFormat, format,
+	texType uint32, param int32) *doubleFramebuffer {
+
+	fbo1 := createFBO(w, h, internalFormat, format, texType, param)
+	fbo2 := createFBO(w, h, internalFormat, format, texType, param)
+
+	return &doubleFramebuffer{w, h, fbo1.texelSizeX, fbo2.texelSizeY, fbo1, fbo2}
+}
+
+func (s *Simulator) resizeFBO(target *Framebuffer, w, h int, internalFormat, format,
+	texType uint32, param int32) *Framebuffer {
+
+	newFBO := createFBO(w, h, internalFormat, format, texType, param)
+	s.copyProgram.Use()
+	s.copyProgram.SetInt("uTexture", int32(target.attach(0)))
+	s.blit(newFBO)
+
+	return newFBO
+}
+
+func (s *Simulator) resizeDoubleFBO(target *doubleFramebuffer, w, h int, internalFormat,
+	format, texType uint32, param int32) *doubleFramebuffer {
+
+	if target.width == w && target.height == h {
+		return target
+	}
+	old := *target
+	target.fbo1 = s.resizeFBO(old.read(), w, h, internalFormat,
+		format, texType, param)
+	target.writeB(createFBO(w, h, internalFormat, format, texType, param))
+	old.delete()
+	target.width = w
+	target.height = h
+	target.texelSizeX = 1.0 / float32(w)
+	target.texelSizeY = 1.0 / float32(h)
+
+	return target
+}
+
+// getResolution scales resolution up along the long side of a width x
+// height canvas, so the short side is resolution texels whichever way the
+// window is oriented.
+func getResolution(resolution, width, height int) (int, int) {
+	aspectRatio := float32(width) / float32(height)
+	if aspectRatio < 1.0 {
+		aspectRatio = 1.0 / aspectRatio
+	}
+
+	min := int(resolution)
+	max := int(float32(resolution) * aspectRatio)
+
+	if width > height {
+		return max, min
+	}
+	return min, max
+}
+
+// Load in dithering texture
+type texture struct {
+	texture uint32
+	width   int32
+	height  int32
+}
+
+func (t *texture) attach(id uint32) uint32 {
+	gl.ActiveTexture(gl.TEXTURE0 + id)
+	gl.BindTexture(gl.TEXTURE_2D, t.texture)
+
+	return id
+}
+
+//go:embed textures/bluenoise.png
+var ditheringPNG []byte
+
+// newDitheringTexture loads the 64x64 blue noise the display pass offsets
+// bloom by, so its gradients do not band.
+func newDitheringTexture() *texture {
+	return createTexture(bytes.NewReader(ditheringPNG))
+}
+
+// createTexture uploads the image read from r as a repeating RGBA texture.
+func createTexture(r io.Reader) *texture {
+	var textureID uint32
+	gl.GenTextures(1, &textureID)
+	gl.BindTexture(gl.TEXTURE_2D, textureID)
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		panic(err)
+	}
+
+	data := image.NewRGBA(img.Bounds())
+	if data.Stride != data.Rect.Size().X*4 {
+		panic("Unsupported stride")
+	}
+	draw.Draw(data, data.Bounds(), img, image.Point{0, 0}, draw.Src)
+
+	width, height := int32(data.Rect.Size().X), int32(data.Rect.Size().Y)
+	gl.BindTexture(gl.TEXTURE_2D, textureID)
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		gl.RGBA,
+		width,
+		height,
+		0,
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(data.Pix))
+	gl.GenerateMipmap(gl.TEXTURE_2D)
+
+	// Set texture parameters for wrapping
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER,
+		gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+
+	return &texture{textureID, width, height}
+}
+
+// Render function
+func initBlit() uint32 {
+	var VAO, VBO, EBO uint32
+	gl.GenVertexArrays(1, &VAO)
+	gl.GenBuffers(1, &VBO)
+	gl.GenBuffers(1, &EBO)
+
+	gl.BindVertexArray(VAO)
+
+	vertices := []float32{-1, -1, -1, 1, 1, 1, 1, -1}
+	gl.BindBuffer(gl.ARRAY_BUFFER, VBO)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4,
+		gl.Ptr(vertices), gl.STATIC_DRAW)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 0, gl.PtrOffset(0)) // is the 0 right? No offset?
+	gl.EnableVertexAttribArray(0)
+
+	eboVertices := []uint16{0, 1, 2, 0, 2, 3}
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, EBO)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(eboVertices)*2,
+		gl.Ptr(eboVertices), gl.STATIC_DRAW)
+
+	return VAO
+}
+
+func (s *Simulator) blit(target *Framebuffer) {
+	if target == nil {
+		gl.Viewport(0, 0, int32(s.width), int32(s.height))
+		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	} else {
+		gl.Viewport(0, 0, int32(target.width), int32(target.height))
+		gl.BindFramebuffer(gl.FRAMEBUFFER, target.fbo)
+	}
+
+	/*
+		if clear {
+			gl.ClearColor(0.0, 0.0, 0.0, 1.0)
+			gl.Clear(gl.COLOR_BUFFER_BIT)
+		}
+	*/
+
+	// Each simulator owns its quad so several can share a context.
+	gl.BindVertexArray(s.vao)
+	gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_SHORT, gl.PtrOffset(0))
+}
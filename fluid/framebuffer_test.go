@@ -0,0 +1,23 @@
+package fluid
+
+import "testing"
+
+func TestGetResolution(t *testing.T) {
+	tests := []struct {
+		resolution, width, height int
+		wantW, wantH              int
+	}{
+		{128, 800, 800, 128, 128},
+		{128, 1600, 800, 256, 128},
+		{128, 800, 1600, 128, 256},
+		{256, 1920, 1080, 455, 256},
+	}
+
+	for _, tt := range tests {
+		w, h := getResolution(tt.resolution, tt.width, tt.height)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("getResolution(%d, %d, %d) = %d, %d, want %d, %d",
+				tt.resolution, tt.width, tt.height, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
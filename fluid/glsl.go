@@ -0,0 +1,579 @@
+package fluid
+
+const baseVertexShader = `
+    #version 410 core
+    precision highp float;
+
+    layout (location = 0) in vec2 aPosition;
+
+    out highp vec2 vUv;
+    out highp vec2 vL;
+    out highp vec2 vR;
+    out highp vec2 vT;
+    out highp vec2 vB;
+
+    uniform highp vec2 texelSize;    
+    //out highp vec2 texelSize;
+
+    void main () {
+        vUv = aPosition * 0.5 + 0.5;
+        vL = vUv - vec2(texelSize.x, 0.0);
+        vR = vUv + vec2(texelSize.x, 0.0);
+        vT = vUv + vec2(0.0, texelSize.y);
+        vB = vUv - vec2(0.0, texelSize.y);
+        gl_Position = vec4(aPosition, 0.0, 1.0);
+    }
+`
+
+const curlShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    in highp vec2 vL;
+    in highp vec2 vR;
+    in highp vec2 vT;
+    in highp vec2 vB;
+    uniform sampler2D uVelocity;
+
+    void main () {
+        float L = texture2D(uVelocity, vL).y;
+        float R = texture2D(uVelocity, vR).y;
+        float T = texture2D(uVelocity, vT).x;
+        float B = texture2D(uVelocity, vB).x;
+        float vorticity = R - L - T + B;
+        FragColor = vec4(0.5 * vorticity, 0.0, 0.0, 1.0);
+    }
+`
+
+const vorticityShader = `
+    #version 410 core
+ 
+    precision highp float;
+    precision highp sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    in highp vec2 vL;
+    in highp vec2 vR;
+    in highp vec2 vT;
+    in highp vec2 vB;
+
+    uniform sampler2D uVelocity;
+    uniform sampler2D uCurl;
+    uniform float curl;
+    uniform float dt;
+
+    void main () {
+        float L = texture2D(uCurl, vL).x;
+        float R = texture2D(uCurl, vR).x;
+        float T = texture2D(uCurl, vT).x;
+        float B = texture2D(uCurl, vB).x;
+        float C = texture2D(uCurl, vUv).x;
+
+        vec2 force = 0.5 * vec2(abs(T) - abs(B), abs(R) - abs(L));
+        force /= length(force) + 0.0001;
+        force *= curl * C;
+        force.y *= -1.0;
+
+        vec2 velocity = texture2D(uVelocity, vUv).xy;
+        velocity += force * dt;
+        velocity = min(max(velocity, -1000.0), 1000.0);
+        FragColor = vec4(velocity, 0.0, 1.0);
+    }
+`
+
+const divergenceShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    in highp vec2 vL;
+    in highp vec2 vR;
+    in highp vec2 vT;
+    in highp vec2 vB;
+    uniform sampler2D uVelocity;
+
+    void main () {
+        float L = texture2D(uVelocity, vL).x;
+        float R = texture2D(uVelocity, vR).x;
+        float T = texture2D(uVelocity, vT).y;
+        float B = texture2D(uVelocity, vB).y;
+
+        vec2 C = texture2D(uVelocity, vUv).xy;
+        if (vL.x < 0.0) { L = -C.x; }
+        if (vR.x > 1.0) { R = -C.x; }
+        if (vT.y > 1.0) { T = -C.y; }
+        if (vB.y < 0.0) { B = -C.y; }
+
+        float div = 0.5 * (R - L + T - B);
+        FragColor = vec4(div, 0.0, 0.0, 1.0);
+    }
+`
+
+const clearShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    uniform sampler2D uTexture;
+    uniform float value;
+
+    void main () {
+        FragColor = value * texture2D(uTexture, vUv);
+    }
+`
+const copyShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    uniform sampler2D uTexture;
+
+    void main () {
+        FragColor = texture2D(uTexture, vUv);
+    }
+`
+
+const pressureShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    in highp vec2 vL;
+    in highp vec2 vR;
+    in highp vec2 vT;
+    in highp vec2 vB;
+    uniform sampler2D uPressure;
+    uniform sampler2D uDivergence;
+
+    void main () {
+        float L = texture2D(uPressure, vL).x;
+        float R = texture2D(uPressure, vR).x;
+        float T = texture2D(uPressure, vT).x;
+        float B = texture2D(uPressure, vB).x;
+        float C = texture2D(uPressure, vUv).x;
+        float divergence = texture2D(uDivergence, vUv).x;
+        float pressure = (L + R + B + T - divergence) * 0.25;
+        FragColor = vec4(pressure, 0.0, 0.0, 1.0);
+    }
+`
+
+const gradientSubtractShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    in highp vec2 vL;
+    in highp vec2 vR;
+    in highp vec2 vT;
+    in highp vec2 vB;
+    uniform sampler2D uPressure;
+    uniform sampler2D uVelocity;
+
+    void main () {
+        float L = texture2D(uPressure, vL).x;
+        float R = texture2D(uPressure, vR).x;
+        float T = texture2D(uPressure, vT).x;
+        float B = texture2D(uPressure, vB).x;
+        vec2 velocity = texture2D(uVelocity, vUv).xy;
+        velocity.xy -= vec2(R - L, T - B);
+        FragColor = vec4(velocity, 0.0, 1.0);
+    }
+`
+
+const advectionShader = `
+    #version 410 core
+
+    precision highp float;
+    precision highp sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    uniform sampler2D uVelocity;
+    uniform sampler2D uSource;
+    uniform vec2 texelSize;
+    uniform vec2 dyeTexelSize;
+    uniform float dt;
+    uniform float dissipation;
+
+    vec4 bilerp (sampler2D sam, vec2 uv, vec2 tsize) {
+        vec2 st = uv / tsize - 0.5;
+
+        vec2 iuv = floor(st);
+        vec2 fuv = fract(st);
+
+        vec4 a = texture2D(sam, (iuv + vec2(0.5, 0.5)) * tsize);
+        vec4 b = texture2D(sam, (iuv + vec2(1.5, 0.5)) * tsize);
+        vec4 c = texture2D(sam, (iuv + vec2(0.5, 1.5)) * tsize);
+        vec4 d = texture2D(sam, (iuv + vec2(1.5, 1.5)) * tsize);
+
+        return mix(mix(a, b, fuv.x), mix(c, d, fuv.x), fuv.y);
+    }
+
+    void main () {
+    #ifdef MANUAL_FILTERING
+        vec2 coord = vUv - dt * bilerp(uVelocity, vUv, texelSize).xy * texelSize;
+        vec4 result = bilerp(uSource, coord, dyeTexelSize);
+    #else
+        vec2 coord = vUv - dt * texture2D(uVelocity, vUv).xy * texelSize;
+        vec4 result = texture2D(uSource, coord);
+    #endif
+        float decay = 1.0 + dissipation * dt;
+        FragColor = result / decay;
+    }
+`
+
+const colorShader = `
+    #version 410 core
+
+    precision mediump float;
+
+    out vec4 FragColor;
+
+    uniform vec4 color;
+
+    void main () {
+        FragColor = color;
+    }
+`
+
+const displayShader = `
+    #version 410 core
+
+    precision highp float;
+    precision highp sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    in highp vec2 vL;
+    in highp vec2 vR;
+    in highp vec2 vT;
+    in highp vec2 vB;
+    uniform sampler2D uTexture;
+    uniform sampler2D uBloom;
+    uniform sampler2D uSunrays;
+    uniform sampler2D uDithering;
+    uniform vec2 ditherScale;
+    uniform vec2 texelSize;
+
+    vec3 linearToGamma (vec3 color) {
+        color = max(color, vec3(0));
+        return max(1.055 * pow(color, vec3(0.416666667)) - 0.055, vec3(0));
+    }
+
+    void main () {
+        vec3 c = texture2D(uTexture, vUv).rgb;
+
+    #ifdef SHADING
+        vec3 lc = texture2D(uTexture, vL).rgb;
+        vec3 rc = texture2D(uTexture, vR).rgb;
+        vec3 tc = texture2D(uTexture, vT).rgb;
+        vec3 bc = texture2D(uTexture, vB).rgb;
+
+        float dx = length(rc) - length(lc);
+        float dy = length(tc) - length(bc);
+
+        vec3 n = normalize(vec3(dx, dy, length(texelSize)));
+        vec3 l = vec3(0.0, 0.0, 1.0);
+
+        float diffuse = clamp(dot(n, l) + 0.7, 0.7, 1.0);
+        c *= diffuse;
+    #endif
+
+    #ifdef BLOOM
+        vec3 bloom = texture2D(uBloom, vUv).rgb;
+    #endif
+
+    #ifdef SUNRAYS
+        float sunrays = texture2D(uSunrays, vUv).r;
+        c *= sunrays;
+    #ifdef BLOOM
+        bloom *= sunrays;
+    #endif
+    #endif
+
+    #ifdef BLOOM
+        float noise = texture2D(uDithering, vUv * ditherScale).r;
+        noise = noise * 2.0 - 1.0;
+        bloom += noise / 255.0;
+        bloom = linearToGamma(bloom);
+        c += bloom;
+    #endif
+
+        float a = max(c.r, max(c.g, c.b));
+        FragColor = vec4(c, a);
+    }
+`
+
+// Keeps the part of the dye brighter than the threshold, with a soft knee
+// (a quadratic curve) easing into it.
+const bloomPrefilterShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in vec2 vUv;
+    uniform sampler2D uTexture;
+    uniform vec3 curve;
+    uniform float threshold;
+
+    void main () {
+        vec3 c = texture2D(uTexture, vUv).rgb;
+        float br = max(c.r, max(c.g, c.b));
+        float rq = clamp(br - curve.x, 0.0, curve.y);
+        rq = curve.z * rq * rq;
+        c *= max(rq, br - threshold) / max(br, 0.0001);
+        FragColor = vec4(c, 0.0);
+    }
+`
+
+// Used both to downsample and to upsample the bloom pyramid
+const bloomBlurShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in vec2 vL;
+    in vec2 vR;
+    in vec2 vT;
+    in vec2 vB;
+    uniform sampler2D uTexture;
+
+    void main () {
+        vec4 sum = vec4(0.0);
+        sum += texture2D(uTexture, vL);
+        sum += texture2D(uTexture, vR);
+        sum += texture2D(uTexture, vT);
+        sum += texture2D(uTexture, vB);
+        sum *= 0.25;
+        FragColor = sum;
+    }
+`
+
+const bloomFinalShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in vec2 vL;
+    in vec2 vR;
+    in vec2 vT;
+    in vec2 vB;
+    uniform sampler2D uTexture;
+    uniform float intensity;
+
+    void main () {
+        vec4 sum = vec4(0.0);
+        sum += texture2D(uTexture, vL);
+        sum += texture2D(uTexture, vR);
+        sum += texture2D(uTexture, vT);
+        sum += texture2D(uTexture, vB);
+        sum *= 0.25;
+        FragColor = sum * intensity;
+    }
+`
+
+// Marks where there is no dye, which is what the rays shine through
+const sunraysMaskShader = `
+    #version 410 core
+
+    precision highp float;
+    precision highp sampler2D;
+
+    out vec4 FragColor;
+
+    in vec2 vUv;
+    uniform sampler2D uTexture;
+
+    void main () {
+        vec4 c = texture2D(uTexture, vUv);
+        float br = max(c.r, max(c.g, c.b));
+        c.a = 1.0 - min(max(br * 20.0, 0.0), 0.8);
+        FragColor = c;
+    }
+`
+
+// Radial blur of the mask towards the centre of the screen
+const sunraysShader = `
+    #version 410 core
+
+    precision highp float;
+    precision highp sampler2D;
+
+    out vec4 FragColor;
+
+    in vec2 vUv;
+    uniform sampler2D uTexture;
+    uniform float weight;
+
+    #define ITERATIONS 16
+
+    void main () {
+        float Density = 0.3;
+        float Decay = 0.95;
+        float Exposure = 0.7;
+
+        vec2 coord = vUv;
+        vec2 dir = vUv - 0.5;
+
+        dir *= 1.0 / float(ITERATIONS) * Density;
+        float illuminationDecay = 1.0;
+
+        float color = texture2D(uTexture, vUv).a;
+
+        for (int i = 0; i < ITERATIONS; i++)
+        {
+            coord -= dir;
+            float col = texture2D(uTexture, coord).a;
+            color += col * illuminationDecay * weight;
+            illuminationDecay *= Decay;
+        }
+
+        FragColor = vec4(color * Exposure, 0.0, 0.0, 1.0);
+    }
+`
+
+// One direction of a separable blur, texelSize picks which
+const blurVertexShader = `
+    #version 410 core
+    precision highp float;
+
+    layout (location = 0) in vec2 aPosition;
+
+    out vec2 vUv;
+    out vec2 vL;
+    out vec2 vR;
+
+    uniform vec2 texelSize;
+
+    void main () {
+        vUv = aPosition * 0.5 + 0.5;
+        float offset = 1.33333333;
+        vL = vUv - texelSize * offset;
+        vR = vUv + texelSize * offset;
+        gl_Position = vec4(aPosition, 0.0, 1.0);
+    }
+`
+
+const blurShader = `
+    #version 410 core
+
+    precision mediump float;
+    precision mediump sampler2D;
+
+    out vec4 FragColor;
+
+    in vec2 vUv;
+    in vec2 vL;
+    in vec2 vR;
+    uniform sampler2D uTexture;
+
+    void main () {
+        vec4 sum = texture2D(uTexture, vUv) * 0.29411764;
+        sum += texture2D(uTexture, vL) * 0.35294117;
+        sum += texture2D(uTexture, vR) * 0.35294117;
+        FragColor = sum;
+    }
+`
+
+// Used in adding dye and motion to simulation
+const splatShader = `
+    #version 410 core
+
+    precision highp float;
+    precision highp sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    uniform sampler2D uTarget;
+    uniform float aspectRatio;
+    uniform vec3 color;
+    uniform vec2 point;
+    uniform float radius;
+
+    void main () {
+        vec2 p = vUv - point.xy;
+        p.x *= aspectRatio;
+        vec3 splat = exp(-dot(p, p) / radius) * color;
+        vec3 base = texture2D(uTarget, vUv).xyz;
+        FragColor = vec4(base + splat, 1.0);
+    }
+`
+
+// Shows a simulation field instead of the dye. Vector fields map x and y to
+// red and green, scalar ones positive to red and negative to blue.
+const debugShader = `
+    #version 410 core
+
+    precision highp float;
+    precision highp sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    uniform sampler2D uTexture;
+    uniform float scale;
+    uniform int vector;
+
+    void main () {
+        vec2 v = texture2D(uTexture, vUv).xy * scale;
+        vec3 c = vector == 1 ? vec3(abs(v), 0.0) : vec3(max(v.x, 0.0), 0.0, max(-v.x, 0.0));
+        FragColor = vec4(c, 1.0);
+    }
+`
+
+// Draws an image over the display, flipped as image rows start at the top.
+const overlayShader = `
+    #version 410 core
+
+    precision highp float;
+    precision highp sampler2D;
+
+    out vec4 FragColor;
+
+    in highp vec2 vUv;
+    uniform sampler2D uTexture;
+
+    void main () {
+        vec4 c = texture2D(uTexture, vec2(vUv.x, 1.0 - vUv.y));
+        FragColor = vec4(c.rgb * c.a, c.a);
+    }
+`
@@ -0,0 +1,64 @@
+package fluid
+
+import (
+	"image"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Overlay is an image uploaded to draw over the display, such as text.
+type Overlay struct {
+	texture uint32
+	width   int
+	height  int
+}
+
+// NewOverlay uploads img. A GL context must be current.
+func NewOverlay(img *image.RGBA) *Overlay {
+	size := img.Rect.Size()
+	o := &Overlay{width: size.X, height: size.Y}
+
+	gl.GenTextures(1, &o.texture)
+	gl.BindTexture(gl.TEXTURE_2D, o.texture)
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, int32(img.Stride/4))
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(size.X), int32(size.Y), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+
+	return o
+}
+
+// Delete frees the texture.
+func (o *Overlay) Delete() {
+	gl.DeleteTextures(1, &o.texture)
+}
+
+// RenderOverlay blends o over target at its own pixel size, with its top
+// left corner at (x, y) pixels from the top left of target.
+func (s *Simulator) RenderOverlay(o *Overlay, target *Framebuffer, x, y int) {
+	height := s.height
+	if target != nil {
+		height = target.height
+	}
+
+	s.overlayProgram.Use()
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, o.texture)
+	s.overlayProgram.SetInt("uTexture", 0)
+
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	gl.Enable(gl.BLEND)
+	if target == nil {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	} else {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, target.fbo)
+	}
+	gl.Viewport(int32(x), int32(height-y-o.height), int32(o.width), int32(o.height))
+	gl.BindVertexArray(s.vao)
+	gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_SHORT, gl.PtrOffset(0))
+	gl.Disable(gl.BLEND)
+}
@@ -0,0 +1,288 @@
+package fluid
+
+import (
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Pointer tracks one finger or mouse moving over the canvas.
+type Pointer struct {
+	id            int
+	texcoordX     float32
+	texcoordY     float32
+	prevTexcoordX float32
+	prevTexcoordY float32
+	deltaX        float32
+	deltaY        float32
+	down          bool
+	moved         bool
+	pressure      float32
+	color         mgl.Vec3
+
+	// The sample before prevTexcoord, to fit a spline through the stroke.
+	lastTexcoordX float32
+	lastTexcoordY float32
+}
+
+func newPointer(id int) *Pointer {
+	return &Pointer{
+		id:    id,
+		color: generateColor(),
+	}
+}
+
+// pointerInput turns pointer events into splats on a backend. Simulator and
+// CPUSolver each own one, so both take the same input and replay the same
+// sessions.
+type pointerInput struct {
+	backend  inputBackend
+	pointers []*Pointer
+	events   chan PointerEvent
+	dropped  atomic.Int64 // events PushPointerEvent had no room for
+	recorder *Recorder
+
+	colorUpdateTimer float32 // new pointer colours when it reaches 1
+}
+
+// inputBackend is what pointerInput needs from the backend it splats on.
+type inputBackend interface {
+	Size() (int, int)
+	config() *Config
+	splat(x, y, dx, dy float32, col mgl.Vec3, radius float32)
+}
+
+func newPointerInput(backend inputBackend) *pointerInput {
+	return &pointerInput{
+		backend: backend,
+		events:  make(chan PointerEvent, pointerQueueSize),
+	}
+}
+
+// pointerByID returns the pointer with the given id, for example a
+// multitouch tracking ID, creating it with its own colour the first time.
+func (in *pointerInput) pointerByID(id int) *Pointer {
+	for _, pointer := range in.pointers {
+		if pointer.id == id {
+			return pointer
+		}
+	}
+
+	pointer := newPointer(id)
+	in.pointers = append(in.pointers, pointer)
+	return pointer
+}
+
+// removePointer forgets the pointer with the given id, once its finger
+// lifted.
+func (in *pointerInput) removePointer(id int) {
+	for i, pointer := range in.pointers {
+		if pointer.id == id {
+			in.pointers = append(in.pointers[:i], in.pointers[i+1:]...)
+			return
+		}
+	}
+}
+
+// updatePointerDownData starts a stroke at (posX, posY), given like in
+// updatePointerMoveData. The previous position is reset so the first move of
+// the stroke does not splat all the way from where the last one ended.
+func (in *pointerInput) updatePointerDownData(pointer *Pointer, posX, posY float32) {
+	width, height := in.backend.Size()
+	pointer.down = true
+	pointer.moved = false
+	pointer.texcoordX = posX / float32(width)
+	pointer.texcoordY = 1.0 - posY/float32(height)
+	pointer.prevTexcoordX = pointer.texcoordX
+	pointer.prevTexcoordY = pointer.texcoordY
+	pointer.lastTexcoordX = pointer.texcoordX
+	pointer.lastTexcoordY = pointer.texcoordY
+	pointer.deltaX = 0
+	pointer.deltaY = 0
+}
+
+// updatePointerMoveData moves pointer to (posX, posY) given in canvas pixels
+// with the origin in the top left corner.
+func (in *pointerInput) updatePointerMoveData(pointer *Pointer, posX, posY float32) {
+	width, height := in.backend.Size()
+	pointer.lastTexcoordX = pointer.prevTexcoordX
+	pointer.lastTexcoordY = pointer.prevTexcoordY
+	pointer.prevTexcoordX = pointer.texcoordX
+	pointer.prevTexcoordY = pointer.texcoordY
+	pointer.texcoordX = posX / float32(width)
+	pointer.texcoordY = 1.0 - posY/float32(height)
+	pointer.deltaX = in.correctDeltaX(pointer.texcoordX -
+		pointer.prevTexcoordX)
+	pointer.deltaY = in.correctDeltaY(pointer.texcoordY -
+		pointer.prevTexcoordY)
+
+	pointer.moved = math.Abs(float64(pointer.deltaX)) > 0.0 ||
+		math.Abs(float64(pointer.deltaY)) > 0.0
+}
+
+func (in *pointerInput) aspectRatio() float32 {
+	width, height := in.backend.Size()
+	return float32(width) / float32(height)
+}
+
+func (in *pointerInput) correctDeltaX(delta float32) float32 {
+	if aspectRatio := in.aspectRatio(); aspectRatio < 1 {
+		delta *= aspectRatio
+	}
+	return delta
+}
+
+func (in *pointerInput) correctDeltaY(delta float32) float32 {
+	if aspectRatio := in.aspectRatio(); aspectRatio < 1 {
+		delta /= aspectRatio
+	}
+	return delta
+}
+
+// PointerEventType says what a PointerEvent does to its pointer.
+type PointerEventType int
+
+const (
+	PointerMove PointerEventType = iota
+	PointerDown
+	PointerUp
+)
+
+// PointerEvent is one input sample. X and Y are normalized to [0, 1] with
+// the origin in the top left corner, so input goroutines never need the
+// canvas size. Pressure is normalized to [0, 1] against the device's range,
+// 0 when the device does not report it.
+type PointerEvent struct {
+	Time     time.Time `json:"-"`
+	Type     PointerEventType
+	ID       int
+	X        float32
+	Y        float32
+	Pressure float32
+}
+
+// pointerQueueSize is how many events may be pending between two frames
+// before PushPointerEvent drops them. A touchpad sends a few hundred a
+// second, so only a render loop stalled for seconds gets there.
+const pointerQueueSize = 4096
+
+// PushPointerEvent queues ev for the next ApplyInputs. Unlike the other
+// methods it is safe to call from any goroutine. It never blocks, so it is
+// also safe from the thread calling ApplyInputs. The price is a bound:
+// while pointerQueueSize events are already pending ev is dropped, whatever
+// its type, and the next ApplyInputs logs how many were.
+func (s *Simulator) PushPointerEvent(ev PointerEvent) {
+	s.input.push(ev)
+}
+
+// ApplyInputs applies the pointer events queued so far, in order, splatting
+// every move. Events pushed while it runs wait for the next call. Every
+// queued event is applied exactly once, but at most pointerQueueSize can be
+// queued between two calls, see PushPointerEvent.
+func (s *Simulator) ApplyInputs() {
+	s.input.applyQueued()
+}
+
+func (s *Simulator) inputs() *pointerInput {
+	return s.input
+}
+
+func (in *pointerInput) push(ev PointerEvent) {
+	select {
+	case in.events <- ev:
+	default:
+		in.dropped.Add(1)
+	}
+}
+
+func (in *pointerInput) applyQueued() {
+	if n := in.dropped.Swap(0); n > 0 {
+		log.Println("fluid: pointer queue full, dropped", n, "events")
+	}
+	for n := len(in.events); n > 0; n-- {
+		in.applyInput(<-in.events, nil)
+	}
+}
+
+// applyInput applies ev straight away. A replay passes the colour the
+// pointer had when ev was recorded, nil keeps the pointer's own. An attached
+// recorder gets ev with the colour the pointer splats it with.
+func (in *pointerInput) applyInput(ev PointerEvent, color *mgl.Vec3) {
+	var used *mgl.Vec3
+	if ev.Type != PointerUp {
+		pointer := in.pointerByID(ev.ID)
+		if color != nil {
+			pointer.color = *color
+		}
+		c := pointer.color
+		used = &c
+	}
+
+	if in.recorder != nil {
+		in.recorder.pointer(ev, used)
+	}
+	in.applyPointerEvent(ev)
+}
+
+func (in *pointerInput) applyPointerEvent(ev PointerEvent) {
+	width, height := in.backend.Size()
+	x, y := ev.X*float32(width), ev.Y*float32(height)
+	switch ev.Type {
+	case PointerDown:
+		pointer := in.pointerByID(ev.ID)
+		pointer.pressure = ev.Pressure
+		in.updatePointerDownData(pointer, x, y)
+	case PointerMove:
+		// Devices that never report a touch start their stroke here, so it
+		// does not splat from wherever the pointer was created.
+		pointer := in.pointerByID(ev.ID)
+		pointer.pressure = ev.Pressure
+		if !pointer.down {
+			in.updatePointerDownData(pointer, x, y)
+			return
+		}
+		in.updatePointerMoveData(pointer, x, y)
+		in.splatPointer(pointer)
+	case PointerUp:
+		in.removePointer(ev.ID)
+	}
+}
+
+func (in *pointerInput) splatPointer(pointer *Pointer) {
+	if !pointer.moved {
+		return
+	}
+	pointer.moved = false
+
+	c := in.backend.config()
+	radius := c.SPLAT_RADIUS / 100.0 * in.pressureScale(pointer, c.TOUCH_PRESSURE_RADIUS)
+	force := c.SPLAT_FORCE * in.pressureScale(pointer, c.TOUCH_PRESSURE_FORCE)
+	color := pointer.color.Mul(in.pressureScale(pointer, c.TOUCH_PRESSURE_DYE))
+
+	// Each splat pushes along its own piece of the stroke, so together
+	// they add the force of the whole move.
+	x, y := pointer.prevTexcoordX, pointer.prevTexcoordY
+	for _, p := range in.strokePoints(pointer, radius) {
+		dx := in.correctDeltaX(p.X()-x) * force
+		dy := in.correctDeltaY(p.Y()-y) * force
+		in.backend.splat(p.X(), p.Y(), dx, dy, color, radius)
+		x, y = p.X(), p.Y()
+	}
+}
+
+// pressureScale maps the pointer's pressure through TOUCH_PRESSURE_CURVE
+// onto a factor between 1-amount and 1+amount. Pointers without pressure
+// get 1, so the mouse splats as before.
+func (in *pointerInput) pressureScale(pointer *Pointer, amount float32) float32 {
+	c := in.backend.config()
+	if !c.TOUCH_PRESSURE || pointer.pressure <= 0 {
+		return 1
+	}
+
+	p := float32(math.Pow(float64(clamp(pointer.pressure, 0, 1)),
+		float64(c.TOUCH_PRESSURE_CURVE)))
+	return mix(1-amount, 1+amount, p)
+}
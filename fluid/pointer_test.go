@@ -0,0 +1,112 @@
+package fluid
+
+import (
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// splatSink is an inputBackend recording splats instead of drawing them.
+type splatSink struct {
+	width, height int
+	cfg           Config
+	splats        []sinkSplat
+}
+
+type sinkSplat struct {
+	x, y, dx, dy float32
+	color        mgl.Vec3
+	radius       float32
+}
+
+func newSplatSink(width, height int) *splatSink {
+	return &splatSink{width: width, height: height, cfg: DefaultConfig()}
+}
+
+func (s *splatSink) Size() (int, int) { return s.width, s.height }
+func (s *splatSink) config() *Config  { return &s.cfg }
+
+func (s *splatSink) splat(x, y, dx, dy float32, col mgl.Vec3, radius float32) {
+	s.splats = append(s.splats, sinkSplat{x, y, dx, dy, col, radius})
+}
+
+func TestPointerDownDoesNotSplatJump(t *testing.T) {
+	ev := func(typ PointerEventType, x, y float32) PointerEvent {
+		return PointerEvent{Type: typ, X: x, Y: y}
+	}
+	first := []PointerEvent{ev(PointerDown, 0.1, 0.1), ev(PointerMove, 0.2, 0.1)}
+
+	tests := []struct {
+		name string
+		gap  []PointerEvent // between the first stroke and the second down
+	}{
+		{"after an up", []PointerEvent{ev(PointerUp, 0, 0)}},
+		{"up lost", nil},
+		{"moves while up", []PointerEvent{ev(PointerUp, 0, 0), ev(PointerMove, 0.5, 0.5)}},
+	}
+
+	for _, tt := range tests {
+		sink := newSplatSink(100, 100)
+		in := newPointerInput(sink)
+		for _, e := range append(first, tt.gap...) {
+			in.applyInput(e, nil)
+		}
+
+		sink.splats = nil
+		in.applyInput(ev(PointerDown, 0.9, 0.9), nil)
+		if len(sink.splats) != 0 {
+			t.Errorf("%s: the down splatted %+v", tt.name, sink.splats)
+		}
+		in.applyInput(ev(PointerMove, 0.92, 0.9), nil)
+		if len(sink.splats) == 0 {
+			t.Errorf("%s: the move did not splat", tt.name)
+		}
+
+		// The stroke runs from (0.9, 0.1) to (0.92, 0.1) in texture
+		// coordinates, y pointing up.
+		var dx, dy float32
+		for _, s := range sink.splats {
+			if s.x < 0.9 || s.x > 0.92+1e-5 || abs(s.y-0.1) > 1e-5 {
+				t.Errorf("%s: splat at (%g, %g) outside the move", tt.name, s.x, s.y)
+			}
+			dx, dy = dx+s.dx, dy+s.dy
+		}
+		if want := 0.02 * sink.cfg.SPLAT_FORCE; abs(dx-want) > 1e-2 || abs(dy) > 1e-2 {
+			t.Errorf("%s: the move pushed (%g, %g), want (%g, 0)", tt.name, dx, dy, want)
+		}
+	}
+}
+
+func TestPressureScale(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		curve    float32
+		pressure float32
+		amount   float32
+		want     float32
+	}{
+		{"off", false, 1, 1, 0.5, 1},
+		{"missing", true, 1, 0, 0.5, 1},
+		{"negative", true, 1, -0.5, 0.5, 1},
+		{"light", true, 1, 0.01, 0.5, 0.51},
+		{"half", true, 1, 0.5, 0.5, 1},
+		{"full", true, 1, 1, 0.5, 1.5},
+		{"clamped", true, 1, 3, 0.5, 1.5},
+		{"full amount", true, 1, 1, 1, 2},
+		{"no amount", true, 1, 1, 0, 1},
+		{"curve", true, 2, 0.5, 0.5, 0.75},
+	}
+
+	for _, tt := range tests {
+		sink := newSplatSink(100, 100)
+		sink.cfg.TOUCH_PRESSURE = tt.enabled
+		sink.cfg.TOUCH_PRESSURE_CURVE = tt.curve
+		in := newPointerInput(sink)
+		pointer := &Pointer{pressure: tt.pressure}
+		if got := in.pressureScale(pointer, tt.amount); abs(got-tt.want) > 1e-5 {
+			t.Errorf("%s: pressureScale(%g, %g) = %g, want %g",
+				tt.name, tt.pressure, tt.amount, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package fluid
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed presets
+var builtinPresets embed.FS
+
+// Preset is a named JSON or TOML config file that overrides a subset of the
+// Config fields.
+type Preset struct {
+	Name string
+	file string
+	data []byte
+}
+
+// Apply returns c with the fields set by the preset overridden.
+func (p Preset) Apply(c Config) (Config, error) {
+	if err := c.decode(p.file, p.data); err != nil {
+		return c, fmt.Errorf("preset %s (%s): %v", p.Name, p.file, err)
+	}
+
+	return c, nil
+}
+
+// LoadPresets returns the presets shipped with the package followed by the
+// .json and .toml files in dir, named after the file without its extension.
+// A file in dir replaces the builtin preset of the same name. An empty dir
+// only loads the builtin presets.
+func LoadPresets(dir string) ([]Preset, error) {
+	entries, err := builtinPresets.ReadDir("presets")
+	if err != nil {
+		return nil, err
+	}
+	var presets []Preset
+	for _, entry := range entries {
+		file := path.Join("presets", entry.Name())
+		data, err := builtinPresets.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, newPreset(file, data))
+	}
+	if dir == "" {
+		return presets, nil
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var user []Preset
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if f.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+		file := filepath.Join(dir, f.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		preset := newPreset(file, data)
+		// Catch mistakes at startup rather than when the preset is picked.
+		if _, err := preset.Apply(DefaultConfig()); err != nil {
+			return nil, err
+		}
+		user = append(user, preset)
+	}
+	sort.Slice(user, func(i, j int) bool { return user[i].Name < user[j].Name })
+
+	for _, preset := range user {
+		if i := FindPreset(presets, preset.Name); i >= 0 {
+			presets[i] = preset
+		} else {
+			presets = append(presets, preset)
+		}
+	}
+
+	return presets, nil
+}
+
+// FindPreset returns the index of the preset called name, or -1.
+func FindPreset(presets []Preset, name string) int {
+	for i, preset := range presets {
+		if preset.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func newPreset(file string, data []byte) Preset {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	return Preset{name, file, data}
+}
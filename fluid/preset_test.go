@@ -0,0 +1,117 @@
+package fluid
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPresets(t *testing.T) {
+	builtin, err := LoadPresets("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"screensaver", "swirls", "thick-smoke", "thin-ink"}
+	if len(builtin) != len(names) {
+		t.Fatalf("got %d builtin presets, want %d", len(builtin), len(names))
+	}
+	for i, name := range names {
+		if builtin[i].Name != name {
+			t.Errorf("builtin preset %d is %q, want %q", i, builtin[i].Name, name)
+		}
+		if _, err := builtin[i].Apply(DefaultConfig()); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A user swirls replaces the builtin one in place, the others are added
+	// after the builtin presets by name. Other files are ignored.
+	dir := t.TempDir()
+	files := map[string]string{
+		"swirls.toml": "CURL = 1\n",
+		"zoom.json":   `{"SPLAT_RADIUS": 0.9}`,
+		"calm.TOML":   "CURL = 2\n",
+		"notes.txt":   "not a preset",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "old.toml"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	presets, err := LoadPresets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, preset := range presets {
+		got = append(got, preset.Name)
+	}
+	want := "screensaver swirls thick-smoke thin-ink calm zoom"
+	if strings.Join(got, " ") != want {
+		t.Errorf("presets %v, want %s", got, want)
+	}
+
+	swirls, err := presets[FindPreset(presets, "swirls")].Apply(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := DefaultConfig(); swirls.CURL != 1 || swirls.SPLAT_FORCE != want.SPLAT_FORCE {
+		t.Errorf("user swirls gave CURL %v, SPLAT_FORCE %v, want 1 and the default",
+			swirls.CURL, swirls.SPLAT_FORCE)
+	}
+}
+
+func TestLoadPresetsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"broken.toml", "CURL = = 1\n"},
+		{"typo.toml", "CURLS = 1\n"},
+		{"wrong.json", `{"CURL": "high"}`},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadPresets(dir)
+		if err == nil {
+			t.Errorf("%s: LoadPresets succeeded", tt.name)
+		} else if !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: error %q does not name the file", tt.name, err)
+		}
+	}
+
+	if _, err := LoadPresets(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadPresets read a missing directory")
+	}
+}
+
+func TestFindPreset(t *testing.T) {
+	presets, err := LoadPresets("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"screensaver", 0},
+		{"thin-ink", 3},
+		{"Thin-Ink", -1},
+		{"thin-ink.toml", -1},
+		{"", -1},
+	}
+	for _, tt := range tests {
+		if got := FindPreset(presets, tt.name); got != tt.want {
+			t.Errorf("FindPreset(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
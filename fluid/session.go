@@ -0,0 +1,171 @@
+package fluid
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// A session file is JSON lines: a header with the seed, then one line per
+// step, pointer event, splat, config change or reset in the order the
+// simulator went through them. Steps carry their dt, so the events between
+// two steps replay between the same two steps whatever the frame rate.
+type sessionHeader struct {
+	Seed int64 `json:"seed"`
+}
+
+type sessionEvent struct {
+	Step    *float32      `json:"step,omitempty"` // the dt of a Step
+	Pointer *PointerEvent `json:"pointer,omitempty"`
+	Splat   *sessionSplat `json:"splat,omitempty"`
+	Config  *Config       `json:"config,omitempty"`
+	Reset   bool          `json:"reset,omitempty"`
+
+	// Color is the pointer's colour when the event was applied, so a replay
+	// does not depend on the random source handing out the same colours.
+	Color *mgl.Vec3 `json:"color,omitempty"`
+}
+
+type sessionSplat struct {
+	X, Y, DX, DY float32
+	Color        mgl.Vec3
+}
+
+// Recorder writes an input session while attached with Simulator.Record.
+type Recorder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder starts a session on w. seed is the one the math/rand source
+// was seeded with. Pointer colours and splats are recorded as they were
+// used, the seed only repeats randomness a session does not record.
+func NewRecorder(w io.Writer, seed int64) *Recorder {
+	bw := bufio.NewWriter(w)
+	r := &Recorder{w: bw, enc: json.NewEncoder(bw)}
+	r.write(sessionHeader{seed})
+	return r
+}
+
+func (r *Recorder) write(v interface{}) {
+	if r.err == nil {
+		r.err = r.enc.Encode(v)
+	}
+}
+
+func (r *Recorder) step(dt float32) {
+	r.write(sessionEvent{Step: &dt})
+}
+
+func (r *Recorder) pointer(ev PointerEvent, color *mgl.Vec3) {
+	r.write(sessionEvent{Pointer: &ev, Color: color})
+}
+
+func (r *Recorder) splat(x, y, dx, dy float32, col mgl.Vec3) {
+	r.write(sessionEvent{Splat: &sessionSplat{x, y, dx, dy, col}})
+}
+
+func (r *Recorder) config(c Config) {
+	r.write(sessionEvent{Config: &c})
+}
+
+func (r *Recorder) reset() {
+	r.write(sessionEvent{Reset: true})
+}
+
+// Flush writes out buffered events and returns the first error recording
+// ran into.
+func (r *Recorder) Flush() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
+
+// Record attaches r so every Step, pointer event ApplyInputs applies,
+// Splat, including the ones from MultipleSplats, SetConfig and Reset is
+// written to it, starting with the current config. nil stops recording.
+func (s *Simulator) Record(r *Recorder) {
+	s.input.recorder = r
+	if r != nil {
+		r.config(s.Config)
+	}
+}
+
+// Replay feeds a recorded session back into a Simulator or CPUSolver. It
+// hands out the steps the recording took with their dt, so replaying the
+// same file is repeatable bit for bit and matches the recorded run, as long
+// as the canvas size is the same: resizes are not recorded.
+type Replay struct {
+	Seed int64
+
+	dec  *json.Decoder
+	next sessionEvent
+	done bool
+	err  error
+}
+
+// NewReplay reads the header of a session written by Recorder. Seed the
+// math/rand source with Replay.Seed before creating the simulator, for
+// anything random the session did not record.
+func NewReplay(r io.Reader) (*Replay, error) {
+	dec := json.NewDecoder(r)
+	var header sessionHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("replay: %v", err)
+	}
+
+	replay := &Replay{Seed: header.Seed, dec: dec}
+	replay.read()
+	return replay, replay.err
+}
+
+func (r *Replay) read() {
+	r.next = sessionEvent{}
+	if err := r.dec.Decode(&r.next); err != nil {
+		r.done = true
+		if err != io.EOF {
+			r.err = fmt.Errorf("replay: %v", err)
+		}
+	}
+}
+
+// Advance applies the events recorded before the next step, in the order
+// they were recorded, and returns the dt to Step with next. Pointer events
+// bypass the PushPointerEvent queue, so a replay cannot fill it up. Config
+// changes keep s's PAUSED, since the steps are recorded already and the
+// replay is paused by not calling Advance. It reports false, after applying
+// what was left, once the session is over.
+func (r *Replay) Advance(s Solver) (float32, bool) {
+	for !r.done {
+		ev := r.next
+		r.read()
+		switch {
+		case ev.Step != nil:
+			return *ev.Step, true
+		case ev.Pointer != nil:
+			s.inputs().applyInput(*ev.Pointer, ev.Color)
+		case ev.Splat != nil:
+			s.Splat(ev.Splat.X, ev.Splat.Y, ev.Splat.DX, ev.Splat.DY, ev.Splat.Color)
+		case ev.Config != nil:
+			config := *ev.Config
+			config.PAUSED = s.inputs().backend.config().PAUSED
+			if err := s.SetConfig(config); err != nil {
+				r.done, r.err = true, fmt.Errorf("replay: %v", err)
+			}
+		case ev.Reset:
+			s.Reset()
+		}
+	}
+
+	return 0, false
+}
+
+// Err returns the error that ended the replay early, if any.
+func (r *Replay) Err() error {
+	return r.err
+}
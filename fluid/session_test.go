@@ -0,0 +1,156 @@
+package fluid
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const (
+	sessionFrames = 40
+	sessionDt     = float32(1.0 / 60)
+)
+
+func newSessionSolver() *CPUSolver {
+	s := newTestSolver(32)
+	s.Config.COLORFUL = true
+	s.Config.COLOR_UPDATE_SPEED = 20 // a few new colours mid stroke
+	return s
+}
+
+// sessionInput returns the pointer events of frame i: one finger drawing a
+// circle for the whole session, and a second one dragging a line through
+// the middle of it.
+func sessionInput(i int) []PointerEvent {
+	a := 2 * math.Pi * float64(i) / sessionFrames
+	circle := PointerEvent{Type: PointerMove, ID: 0,
+		X: 0.5 + 0.3*float32(math.Cos(a)), Y: 0.5 + 0.3*float32(math.Sin(a)),
+		Pressure: 0.5}
+	line := PointerEvent{Type: PointerMove, ID: 1,
+		X: float32(i-10) / 20, Y: 0.5}
+
+	var events []PointerEvent
+	switch {
+	case i == 0:
+		circle.Type = PointerDown
+		events = append(events, circle)
+	case i == sessionFrames-1:
+		circle.Type = PointerUp
+		events = append(events, circle)
+	default:
+		events = append(events, circle)
+	}
+	switch {
+	case i == 10:
+		line.Type = PointerDown
+		events = append(events, line)
+	case i > 10 && i < 30:
+		events = append(events, line)
+	case i == 30:
+		line.Type = PointerUp
+		events = append(events, line)
+	}
+	return events
+}
+
+// sessionStepDt is the dt of frame i, uneven like a live run's.
+func sessionStepDt(i int) float32 {
+	return sessionDt * (1 + float32(i%3)/2)
+}
+
+// recordSession runs the scripted session while recording it, and returns
+// the session and the final frame. Midway the canvas is cleared and the
+// config changed, which the replay has to repeat.
+func recordSession(t *testing.T) ([]byte, *image.RGBA) {
+	t.Helper()
+	rand.Seed(1)
+	s := newSessionSolver()
+	var session bytes.Buffer
+	r := NewRecorder(&session, 1)
+	s.Record(r)
+
+	s.MultipleSplats(3)
+	for i := 0; i < sessionFrames; i++ {
+		switch i {
+		case 12:
+			s.Reset()
+		case 20:
+			config := s.Config
+			config.CURL = 50
+			if err := s.SetConfig(config); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		dt := sessionStepDt(i)
+		s.UpdateColors(dt)
+		for _, ev := range sessionInput(i) {
+			s.PushPointerEvent(ev)
+		}
+		s.ApplyInputs()
+		s.Step(dt)
+	}
+
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return session.Bytes(), s.Snapshot()
+}
+
+// replaySession plays session back on a fresh solver with the math/rand
+// source seeded with seed, and returns the final frame. The solver starts
+// with another config, the session brings its own.
+func replaySession(t *testing.T, session []byte, seed int64) *image.RGBA {
+	t.Helper()
+	replay, err := NewReplay(bytes.NewReader(session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rand.Seed(seed)
+	s := newSessionSolver()
+	s.Config.CURL = 0
+	s.Config.SPLAT_RADIUS = 0.1
+
+	for i := 0; ; i++ {
+		dt, ok := replay.Advance(s)
+		if !ok {
+			if i != sessionFrames {
+				t.Errorf("replay ran %d steps, want %d", i, sessionFrames)
+			}
+			break
+		}
+		if dt != sessionStepDt(i) {
+			t.Errorf("step %d: dt %g, want the recorded %g", i, dt, sessionStepDt(i))
+		}
+		s.UpdateColors(dt)
+		s.ApplyInputs()
+		s.Step(dt)
+	}
+	if err := replay.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Config.CURL != 50 {
+		t.Errorf("replay ended with CURL %g, want the recorded 50", s.Config.CURL)
+	}
+	return s.Snapshot()
+}
+
+func TestReplayIsDeterministic(t *testing.T) {
+	session, live := recordSession(t)
+	if blank := newSessionSolver().Snapshot(); bytes.Equal(live.Pix, blank.Pix) {
+		t.Fatal("the session left no dye")
+	}
+
+	// Different seeds, since the colours and splats must come from the
+	// session rather than the random source.
+	first := replaySession(t, session, 2)
+	second := replaySession(t, session, 3)
+	if !bytes.Equal(first.Pix, second.Pix) {
+		t.Error("two replays of the same session differ")
+	}
+	if !bytes.Equal(first.Pix, live.Pix) {
+		t.Error("the replay differs from the recorded run")
+	}
+}
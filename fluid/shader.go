@@ -0,0 +1,184 @@
+package fluid
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"unsafe"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// material is a program compiled in variants, one per set of #define
+// keywords, like the display program with and without SHADING.
+type material struct {
+	vertexSource   string
+	fragmentSource string
+	programs       map[string]*Shader // variants by joined keywords
+	activeProgram  *Shader
+}
+
+func newMaterial(vsSource, fsSource string) *material {
+	return &material{vsSource, fsSource, map[string]*Shader{}, nil}
+}
+
+// setKeywords makes the variant with keywords defined the active program,
+// compiling it the first time the set is seen.
+func (m *material) setKeywords(keywords []string) {
+	keywords = append([]string(nil), keywords...)
+	sort.Strings(keywords)
+	key := strings.Join(keywords, " ")
+
+	program, ok := m.programs[key]
+	if !ok {
+		program = MakeShaders(m.vertexSource,
+			addKeywords(m.fragmentSource, keywords))
+		m.programs[key] = program
+	}
+	m.activeProgram = program
+}
+
+// addKeywords defines keywords in source, after the #version line which
+// has to come first.
+func addKeywords(source string, keywords []string) string {
+	var defines strings.Builder
+	for _, keyword := range keywords {
+		defines.WriteString("#define " + keyword + "\n")
+	}
+
+	i := strings.Index(source, "#version")
+	if i < 0 {
+		return defines.String() + source
+	}
+	i += strings.IndexByte(source[i:], '\n') + 1
+	return source[:i] + defines.String() + source[i:]
+}
+
+func (m *material) bind() {
+	m.activeProgram.Use()
+}
+
+type Shader struct {
+	ID uint32
+}
+
+func (s *Shader) SetVec4(name string, value mgl.Vec4) {
+	gl.Uniform4fv(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")),
+		1, &value[0])
+}
+
+func (s Shader) SetInt(name string, value int32) {
+	gl.Uniform1i(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")), value)
+}
+
+func (s Shader) SetFloat(name string, value float32) {
+	gl.Uniform1f(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")), value)
+}
+
+func (s Shader) SetVec2(name string, value mgl.Vec2) {
+	gl.Uniform2fv(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")),
+		1, &value[0])
+}
+
+func (s Shader) SetVec3(name string, value mgl.Vec3) {
+	gl.Uniform3fv(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")),
+		1, &value[0])
+}
+
+func MakeShaders(vertexCode, fragmentCode string) *Shader {
+	// Compile the shaders
+	vertexShader := gl.CreateShader(gl.VERTEX_SHADER)
+	shaderSource, freeVertex := gl.Strs(vertexCode + "\x00")
+	defer freeVertex()
+	gl.ShaderSource(vertexShader, 1, shaderSource, nil)
+	gl.CompileShader(vertexShader)
+	checkCompileErrors(vertexShader, "VERTEX", vertexCode)
+
+	fragmentShader := gl.CreateShader(gl.FRAGMENT_SHADER)
+	shaderSource, freeFragment := gl.Strs(fragmentCode + "\x00")
+	defer freeFragment()
+	gl.ShaderSource(fragmentShader, 1, shaderSource, nil)
+	gl.CompileShader(fragmentShader)
+	checkCompileErrors(fragmentShader, "FRAGMENT", fragmentCode)
+
+	// Create a shader program
+	ID := gl.CreateProgram()
+	gl.AttachShader(ID, vertexShader)
+	gl.AttachShader(ID, fragmentShader)
+	gl.LinkProgram(ID)
+
+	checkCompileErrors(ID, "PROGRAM", "")
+
+	// Delete shaders
+	gl.DeleteShader(vertexShader)
+	gl.DeleteShader(fragmentShader)
+
+	return &Shader{ID: ID}
+}
+
+func checkCompileErrors(shader uint32, shaderType, source string) {
+	var success int32
+	var infoLog [1024]byte
+
+	var status uint32 = gl.COMPILE_STATUS
+	stageMessage := "Shader_Compilation_error"
+	errorFunc := gl.GetShaderInfoLog
+	getIV := gl.GetShaderiv
+	if shaderType == "PROGRAM" {
+		status = gl.LINK_STATUS
+		stageMessage = "Program_link_error"
+		errorFunc = gl.GetProgramInfoLog
+		getIV = gl.GetProgramiv
+	}
+
+	getIV(shader, status, &success)
+	if success != 1 {
+		test := &success
+		errorFunc(shader, 1024, test, (*uint8)(unsafe.Pointer(&infoLog)))
+		log.Fatalln("!!!!" + source + stageMessage + shaderType + "|" + string(infoLog[:1024]) + "|")
+	}
+}
+
+func (s Shader) Use() {
+	gl.UseProgram(s.ID)
+}
+
+type shaders struct {
+	curl             *Shader
+	vorticity        *Shader
+	divergence       *Shader
+	clear            *Shader
+	pressure         *Shader
+	gradientSubtract *Shader
+	advection        *Shader
+	color            *Shader
+	splat            *Shader
+	bloomPrefilter   *Shader
+	bloomBlur        *Shader
+	bloomFinal       *Shader
+	sunraysMask      *Shader
+	sunrays          *Shader
+	blur             *Shader
+}
+
+func newShaders() *shaders {
+	return &shaders{
+		MakeShaders(baseVertexShader, curlShader),
+		MakeShaders(baseVertexShader, vorticityShader),
+		MakeShaders(baseVertexShader, divergenceShader),
+		MakeShaders(baseVertexShader, clearShader),
+		MakeShaders(baseVertexShader, pressureShader),
+		MakeShaders(baseVertexShader, gradientSubtractShader),
+		MakeShaders(baseVertexShader, advectionShader),
+		MakeShaders(baseVertexShader, colorShader),
+		MakeShaders(baseVertexShader, splatShader),
+		MakeShaders(baseVertexShader, bloomPrefilterShader),
+		MakeShaders(baseVertexShader, bloomBlurShader),
+		MakeShaders(baseVertexShader, bloomFinalShader),
+		MakeShaders(baseVertexShader, sunraysMaskShader),
+		MakeShaders(baseVertexShader, sunraysShader),
+		MakeShaders(blurVertexShader, blurShader),
+	}
+}
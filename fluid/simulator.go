@@ -0,0 +1,352 @@
+// Package fluid is a GPU fluid simulation, a port of
+// https://github.com/PavelDoGreat/WebGL-Fluid-Simulation to desktop OpenGL.
+package fluid
+
+import (
+	"image"
+	"log"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Simulator owns everything needed to run one fluid simulation: its
+// programs, framebuffers, config and pointers. All methods must be called on
+// the thread that owns the GL context, unless stated otherwise.
+type Simulator struct {
+	Config Config
+
+	width           int
+	height          int
+	vao             uint32
+	programs        *shaders
+	copyProgram     *Shader
+	debugProgram    *Shader
+	overlayProgram  *Shader
+	displayMaterial *material
+	fbos            *framebuffers
+	snapshotFBO     *Framebuffer
+	dithering       *texture
+	input           *pointerInput
+	view            View
+	maxTextureSize  int
+}
+
+// NewSimulator compiles the programs and allocates the framebuffers for a
+// width x height canvas. A GL context must be current.
+func NewSimulator(width, height int, config Config) *Simulator {
+	s := &Simulator{
+		Config: config,
+		width:  width,
+		height: height,
+	}
+	s.input = newPointerInput(s)
+
+	var maxTextureSize int32
+	gl.GetIntegerv(gl.MAX_TEXTURE_SIZE, &maxTextureSize)
+	s.maxTextureSize = int(maxTextureSize)
+	if err := config.validateSize(width, height, s.maxTextureSize); err != nil {
+		log.Fatalln(err)
+	}
+
+	s.vao = initBlit()
+	s.copyProgram = MakeShaders(baseVertexShader, copyShader)
+	s.debugProgram = MakeShaders(baseVertexShader, debugShader)
+	s.overlayProgram = MakeShaders(baseVertexShader, overlayShader)
+	s.programs = newShaders()
+	s.fbos = s.initFramebuffers(nil)
+	s.dithering = newDitheringTexture()
+	s.displayMaterial = newMaterial(baseVertexShader, displayShader)
+	s.displayMaterial.setKeywords(s.displayKeywords())
+
+	return s
+}
+
+// SetConfig switches to a new config, or returns the Validate error and
+// keeps the old one. It is also rejected when a framebuffer would be bigger
+// than the GPU allows for the current window. Scalar parameters are picked
+// up by the next Step, resolution changes and turning BLOOM or SUNRAYS on or
+// off reallocate the framebuffers and display option changes switch the
+// display program variant.
+func (s *Simulator) SetConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if err := config.validateSize(s.width, s.height, s.maxTextureSize); err != nil {
+		return err
+	}
+
+	old := s.Config
+	s.Config = config
+	if s.input.recorder != nil {
+		s.input.recorder.config(config)
+	}
+
+	if old.SIM_RESOLUTION != config.SIM_RESOLUTION ||
+		old.DYE_RESOLUTION != config.DYE_RESOLUTION {
+		s.fbos = s.initFramebuffers(s.fbos)
+	} else {
+		if old.BLOOM != config.BLOOM ||
+			old.BLOOM_RESOLUTION != config.BLOOM_RESOLUTION ||
+			old.BLOOM_ITERATIONS != config.BLOOM_ITERATIONS {
+			s.initBloomFramebuffers(s.fbos)
+		}
+		if old.SUNRAYS != config.SUNRAYS ||
+			old.SUNRAYS_RESOLUTION != config.SUNRAYS_RESOLUTION {
+			s.initSunraysFramebuffers(s.fbos)
+		}
+	}
+	// Variants are cached, so this only compiles a keyword set once.
+	s.displayMaterial.setKeywords(s.displayKeywords())
+
+	return nil
+}
+
+// displayKeywords are the defines the display program variant for the
+// current config is compiled with.
+func (s *Simulator) displayKeywords() []string {
+	keywords := []string{}
+	if s.Config.SHADING {
+		keywords = append(keywords, "SHADING")
+	}
+	if s.Config.BLOOM {
+		keywords = append(keywords, "BLOOM")
+	}
+	if s.Config.SUNRAYS {
+		keywords = append(keywords, "SUNRAYS")
+	}
+
+	return keywords
+}
+
+func (s *Simulator) config() *Config {
+	return &s.Config
+}
+
+// Size returns the canvas size the simulator is rendering for.
+func (s *Simulator) Size() (int, int) {
+	return s.width, s.height
+}
+
+// Resize changes the canvas size, resampling the dye and velocity fields
+// into framebuffers matching the new aspect ratio.
+func (s *Simulator) Resize(w, h int) {
+	s.width = w
+	s.height = h
+	s.fbos = s.initFramebuffers(s.fbos)
+}
+
+// Reset empties the canvas, clearing the dye, velocity and pressure.
+func (s *Simulator) Reset() {
+	if s.input.recorder != nil {
+		s.input.recorder.reset()
+	}
+	fbos := s.fbos
+	fbos.dye.clear()
+	fbos.velocity.clear()
+	fbos.pressure.clear()
+	fbos.divergence.clear()
+	fbos.curl.clear()
+}
+
+// Step function
+func (s *Simulator) Step(dt float32) {
+	if s.input.recorder != nil {
+		s.input.recorder.step(dt)
+	}
+	programs, fbos := s.programs, s.fbos
+	texelSize := mgl.Vec2{fbos.velocity.texelSizeX, fbos.velocity.texelSizeY}
+
+	gl.Disable(gl.BLEND)
+
+	programs.curl.Use()
+	programs.curl.SetVec2("texelSize", texelSize)
+	programs.curl.SetInt("uVelocity", int32(fbos.velocity.read().attach(0)))
+	s.blit(fbos.curl)
+
+	programs.vorticity.Use()
+	programs.vorticity.SetVec2("texelSize", texelSize)
+	programs.vorticity.SetInt("uVelocity", int32(fbos.velocity.read().attach(0)))
+	programs.vorticity.SetInt("uCurl", int32(fbos.curl.attach(1)))
+	programs.vorticity.SetFloat("curl", s.Config.CURL)
+	programs.vorticity.SetFloat("dt", dt)
+	s.blit(fbos.velocity.write())
+	fbos.velocity.swap()
+
+	programs.divergence.Use()
+	programs.divergence.SetVec2("texelSize", texelSize)
+	programs.divergence.SetInt("uVelocity", int32(fbos.velocity.read().attach(0)))
+	s.blit(fbos.divergence)
+
+	programs.clear.Use()
+	programs.clear.SetInt("uTexture", int32(fbos.pressure.read().attach(0)))
+	programs.clear.SetFloat("value", s.Config.PRESSURE)
+	s.blit(fbos.pressure.write())
+	fbos.pressure.swap()
+
+	programs.pressure.Use()
+	programs.pressure.SetVec2("texelSize", texelSize)
+	programs.pressure.SetInt("uDivergence", int32(fbos.divergence.attach(0)))
+	for i := 0; i < s.Config.PRESSURE_ITERATIONS; i++ {
+		programs.pressure.SetInt("uPressure", int32(fbos.pressure.read().attach(1)))
+		s.blit(fbos.pressure.write())
+		fbos.pressure.swap()
+	}
+
+	programs.gradientSubtract.Use()
+	programs.gradientSubtract.SetVec2("texelSize", texelSize)
+	programs.gradientSubtract.SetInt("uPressure", int32(fbos.pressure.read().attach(0)))
+	programs.gradientSubtract.SetInt("uVelocity", int32(fbos.velocity.read().attach(1)))
+	s.blit(fbos.velocity.write())
+	fbos.velocity.swap()
+
+	programs.advection.Use()
+	programs.advection.SetVec2("texelSize", texelSize)
+	// TODO not support linear but we should be good
+	velocityID := int32(fbos.velocity.read().attach(0))
+	programs.advection.SetInt("uVelocity", velocityID)
+	programs.advection.SetInt("uSource", velocityID)
+	programs.advection.SetFloat("dt", dt)
+	programs.advection.SetFloat("dissipation", s.Config.VELOCITY_DISSIPATION)
+	s.blit(fbos.velocity.write())
+	fbos.velocity.swap()
+
+	// TODO not support linear filtering
+	programs.advection.SetInt("uVelocity", int32(fbos.velocity.read().attach(0)))
+	programs.advection.SetInt("uSource", int32(fbos.dye.read().attach(1)))
+	programs.advection.SetFloat("dissipation", s.Config.DENSITY_DISSIPATION)
+	s.blit(fbos.dye.write())
+	fbos.dye.swap()
+}
+
+// Render draws the dye field into target, or to the default framebuffer
+// when target is nil.
+func (s *Simulator) Render(target *Framebuffer) {
+	if s.Config.BLOOM {
+		s.applyBloom(s.fbos.dye.read(), s.fbos.bloom)
+	}
+	if s.Config.SUNRAYS {
+		// The dye's write buffer is free until the next Step.
+		s.applySunrays(s.fbos.dye.read(), s.fbos.dye.write(), s.fbos.sunrays)
+		s.blur(s.fbos.sunrays, s.fbos.sunraysTemp, 1)
+	}
+
+	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	gl.Enable(gl.BLEND)
+
+	s.drawColor(target, mgl.Vec4{0.0, 0.0, 0.0, 1.0})
+	if s.view != ViewDye {
+		s.drawView(target)
+		return
+	}
+	s.drawDisplay(target)
+}
+
+// Snapshot renders the display into an offscreen framebuffer and reads it
+// back as an image the size of the canvas.
+func (s *Simulator) Snapshot() *image.RGBA {
+	if s.snapshotFBO == nil || s.snapshotFBO.width != s.width ||
+		s.snapshotFBO.height != s.height {
+
+		if s.snapshotFBO != nil {
+			s.snapshotFBO.Delete()
+		}
+		s.snapshotFBO = NewFramebuffer(s.width, s.height)
+	}
+	s.Render(s.snapshotFBO)
+
+	img := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	gl.BindFramebuffer(gl.FRAMEBUFFER, s.snapshotFBO.fbo)
+	gl.ReadPixels(0, 0, int32(s.width), int32(s.height), gl.RGBA,
+		gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	// GL rows start at the bottom, image rows at the top.
+	row := make([]byte, img.Stride)
+	for top, bottom := 0, s.height-1; top < bottom; top, bottom = top+1, bottom-1 {
+		topRow := img.Pix[top*img.Stride : (top+1)*img.Stride]
+		bottomRow := img.Pix[bottom*img.Stride : (bottom+1)*img.Stride]
+		copy(row, topRow)
+		copy(topRow, bottomRow)
+		copy(bottomRow, row)
+	}
+
+	return img
+}
+
+func (s *Simulator) drawColor(target *Framebuffer, col mgl.Vec4) {
+	s.programs.color.Use()
+	s.programs.color.SetVec4("color", col)
+	s.blit(target)
+}
+
+func (s *Simulator) drawDisplay(target *Framebuffer) {
+	s.displayMaterial.bind()
+
+	//log.Println(fbos.dye.read().attach(0), int32(fbos.dye.read().attach(0)))
+	s.displayMaterial.activeProgram.SetInt("uTexture",
+		int32(s.fbos.dye.read().attach(0)))
+	w, h := s.width, s.height
+	if target != nil {
+		w, h = target.width, target.height
+	}
+	if s.Config.SHADING {
+		s.displayMaterial.activeProgram.SetVec2("texelSize",
+			mgl.Vec2{1.0 / float32(w), 1.0 / float32(h)})
+	}
+	if s.Config.BLOOM {
+		s.displayMaterial.activeProgram.SetInt("uBloom",
+			int32(s.fbos.bloom.attach(1)))
+		s.displayMaterial.activeProgram.SetInt("uDithering",
+			int32(s.dithering.attach(2)))
+		s.displayMaterial.activeProgram.SetVec2("ditherScale", mgl.Vec2{
+			float32(w) / float32(s.dithering.width),
+			float32(h) / float32(s.dithering.height)})
+	}
+	if s.Config.SUNRAYS {
+		s.displayMaterial.activeProgram.SetInt("uSunrays",
+			int32(s.fbos.sunrays.attach(3)))
+	}
+	s.blit(target)
+}
+
+// MultipleSplats adds n splats of random position, velocity and colour.
+func (s *Simulator) MultipleSplats(n int) {
+	multipleSplats(s, n)
+}
+
+// Splat adds velocity (dx, dy) and dye col around the point (x, y) given in
+// texture coordinates.
+func (s *Simulator) Splat(x, y, dx, dy float32, col mgl.Vec3) {
+	if s.input.recorder != nil {
+		s.input.recorder.splat(x, y, dx, dy, col)
+	}
+	s.splat(x, y, dx, dy, col, s.Config.SPLAT_RADIUS/100.0)
+}
+
+func (s *Simulator) splat(x, y, dx, dy float32, col mgl.Vec3, radius float32) {
+	programs, fbos := s.programs, s.fbos
+
+	programs.splat.Use()
+	programs.splat.SetInt("uTarget", int32(fbos.velocity.read().attach(0)))
+	programs.splat.SetFloat("aspectRatio", float32(s.width)/float32(s.height))
+	programs.splat.SetVec2("point", mgl.Vec2{x, y})
+	programs.splat.SetVec3("color", mgl.Vec3{dx, dy, 0.0})
+	programs.splat.SetFloat("radius", correctRadius(radius, s.width, s.height))
+	s.blit(fbos.velocity.write())
+	fbos.velocity.swap()
+
+	programs.splat.SetInt("uTarget", int32(fbos.dye.read().attach(0)))
+	programs.splat.SetVec3("color", col)
+	s.blit(fbos.dye.write())
+	fbos.dye.swap()
+}
+
+func correctRadius(r float32, width, height int) float32 {
+	aspectRatio := float32(width) / float32(height)
+	if aspectRatio > 1 {
+		r *= aspectRatio
+	}
+	return r
+}
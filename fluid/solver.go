@@ -0,0 +1,47 @@
+package fluid
+
+import (
+	"image"
+	"math/rand"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Solver is the part of a simulation backend that advances and disturbs the
+// fluid. Simulator runs it on the GPU and CPUSolver in plain Go.
+type Solver interface {
+	Step(dt float32)
+	Splat(x, y, dx, dy float32, col mgl.Vec3)
+	MultipleSplats(n int)
+	PushPointerEvent(ev PointerEvent)
+	ApplyInputs()
+	UpdateColors(dt float32)
+	Record(r *Recorder)
+	Resize(w, h int)
+	Size() (int, int)
+	Reset()
+	SetConfig(config Config) error
+	// Snapshot returns what the display pass draws for the current state.
+	// CPUSolver leaves out BLOOM and SUNRAYS.
+	Snapshot() *image.RGBA
+
+	// inputs lets a Replay apply pointer events without the queue.
+	inputs() *pointerInput
+}
+
+var (
+	_ Solver = (*Simulator)(nil)
+	_ Solver = (*CPUSolver)(nil)
+)
+
+// multipleSplats adds n splats of random position, velocity and colour to s.
+func multipleSplats(s Solver, n int) {
+	for i := 0; i < n; i++ {
+		x := rand.Float32()
+		y := rand.Float32()
+		dx := 1000.0 * (rand.Float32() - 0.5)
+		dy := 1000.0 * (rand.Float32() - 0.5)
+		// Brighter than a pointer's, since each is a single splat.
+		s.Splat(x, y, dx, dy, generateColor().Mul(10.0))
+	}
+}
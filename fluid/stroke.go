@@ -0,0 +1,65 @@
+package fluid
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// maxStrokeSplats bounds the splats one move is filled in with, however far
+// the pointer jumped.
+const maxStrokeSplats = 64
+
+// strokePoints returns where to splat along the pointer's last move, from
+// just after prevTexcoord up to and including texcoord, spaced
+// STROKE_SPACING splat widths apart.
+func (in *pointerInput) strokePoints(pointer *Pointer, radius float32) []mgl.Vec2 {
+	p1 := mgl.Vec2{pointer.prevTexcoordX, pointer.prevTexcoordY}
+	p2 := mgl.Vec2{pointer.texcoordX, pointer.texcoordY}
+
+	c := in.backend.config()
+	n := 1
+	if c.STROKE_SPACING > 0 {
+		// The splat shader scales x by the aspect ratio and uses radius as
+		// the variance of its gaussian, so its width is the square root.
+		aspectRatio := in.aspectRatio()
+		w, h := in.backend.Size()
+		width := float32(math.Sqrt(float64(correctRadius(radius, w, h))))
+		d := p2.Sub(p1)
+		dist := mgl.Vec2{d.X() * aspectRatio, d.Y()}.Len()
+		n = int(math.Ceil(float64(dist / (width * c.STROKE_SPACING))))
+		if n < 1 {
+			n = 1
+		}
+		if n > maxStrokeSplats {
+			n = maxStrokeSplats
+		}
+	}
+
+	// The next sample is not known yet, so the spline continues straight.
+	p0 := mgl.Vec2{pointer.lastTexcoordX, pointer.lastTexcoordY}
+	p3 := p2.Mul(2).Sub(p1)
+
+	points := make([]mgl.Vec2, n)
+	for i := range points {
+		t := float32(i+1) / float32(n)
+		if c.STROKE_SPLINE {
+			points[i] = catmullRom(p0, p1, p2, p3, t)
+		} else {
+			points[i] = p1.Add(p2.Sub(p1).Mul(t))
+		}
+	}
+
+	return points
+}
+
+// catmullRom evaluates the uniform Catmull-Rom spline between p1 and p2 at
+// t in [0, 1].
+func catmullRom(p0, p1, p2, p3 mgl.Vec2, t float32) mgl.Vec2 {
+	t2, t3 := t*t, t*t*t
+	return p1.Mul(2).
+		Add(p2.Sub(p0).Mul(t)).
+		Add(p0.Mul(2).Sub(p1.Mul(5)).Add(p2.Mul(4)).Sub(p3).Mul(t2)).
+		Add(p1.Mul(3).Sub(p0).Sub(p2.Mul(3)).Add(p3).Mul(t3)).
+		Mul(0.5)
+}
@@ -0,0 +1,148 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// strokePointer moved from (x1, y1) to (x2, y2), in texture coordinates,
+// coming straight from before (x1, y1).
+func strokePointer(x1, y1, x2, y2 float32) *Pointer {
+	return &Pointer{
+		lastTexcoordX: 2*x1 - x2, lastTexcoordY: 2*y1 - y2,
+		prevTexcoordX: x1, prevTexcoordY: y1,
+		texcoordX: x2, texcoordY: y2,
+		moved: true,
+	}
+}
+
+func TestStrokePoints(t *testing.T) {
+	const radius = 0.005
+	tests := []struct {
+		name           string
+		width, height  int
+		x1, y1, x2, y2 float32
+		spacing        float32
+		want           int
+	}{
+		// A splat is sqrt(0.005) = 0.0707 wide, so 0.5 spacing is 0.0354.
+		{"short", 100, 100, 0.5, 0.5, 0.51, 0.5, 0.5, 1},
+		{"three", 100, 100, 0.5, 0.5, 0.6, 0.5, 0.5, 3},
+		{"diagonal", 100, 100, 0.2, 0.2, 0.3, 0.28, 0.5, 4},
+		{"no spacing", 100, 100, 0.5, 0.5, 0.9, 0.5, 0, 1},
+		{"capped", 100, 100, 0, 0, 1, 1, 0.01, maxStrokeSplats},
+		// Wide canvases stretch x by the aspect ratio, and the splat with
+		// it, sqrt(2*0.005) = 0.1 wide, 0.05 apart.
+		{"wide", 200, 100, 0.5, 0.5, 0.59, 0.5, 0.5, 4},
+		{"wide vertical", 200, 100, 0.5, 0.5, 0.5, 0.59, 0.5, 2},
+		// Tall ones do not scale x, and the radius stays.
+		{"tall", 100, 200, 0.5, 0.5, 0.6, 0.5, 0.5, 2},
+	}
+
+	for _, tt := range tests {
+		for _, spline := range []bool{false, true} {
+			sink := newSplatSink(tt.width, tt.height)
+			sink.cfg.STROKE_SPACING = tt.spacing
+			sink.cfg.STROKE_SPLINE = spline
+			in := newPointerInput(sink)
+			pointer := strokePointer(tt.x1, tt.y1, tt.x2, tt.y2)
+
+			points := in.strokePoints(pointer, radius)
+			if len(points) != tt.want {
+				t.Errorf("%s, spline %v: %d points, want %d",
+					tt.name, spline, len(points), tt.want)
+				continue
+			}
+			if end := points[len(points)-1]; !end.ApproxEqualThreshold(
+				mgl.Vec2{tt.x2, tt.y2}, 1e-5) {
+				t.Errorf("%s, spline %v: ends at %v, want (%g, %g)",
+					tt.name, spline, end, tt.x2, tt.y2)
+			}
+			if spline || tt.spacing == 0 || len(points) == maxStrokeSplats {
+				continue
+			}
+
+			// Straight strokes are evenly spaced, at most STROKE_SPACING
+			// splat widths apart, starting one step after the start.
+			aspectRatio := float32(tt.width) / float32(tt.height)
+			length := func(d mgl.Vec2) float32 {
+				return mgl.Vec2{d.X() * aspectRatio, d.Y()}.Len()
+			}
+			width := float32(math.Sqrt(float64(correctRadius(radius, tt.width, tt.height))))
+			step := length(mgl.Vec2{tt.x2 - tt.x1, tt.y2 - tt.y1}) / float32(len(points))
+			if step > width*tt.spacing {
+				t.Errorf("%s: points are %g apart, want up to %g",
+					tt.name, step, width*tt.spacing)
+			}
+			prev := mgl.Vec2{tt.x1, tt.y1}
+			for i, p := range points {
+				if dist := length(p.Sub(prev)); abs(dist-step) > 1e-5 {
+					t.Errorf("%s: point %d is %g from the last, want %g",
+						tt.name, i, dist, step)
+				}
+				prev = p
+			}
+		}
+	}
+}
+
+func TestStrokeForce(t *testing.T) {
+	tests := []struct {
+		name           string
+		width, height  int
+		x1, y1, x2, y2 float32
+	}{
+		{"square", 100, 100, 0.2, 0.3, 0.5, 0.4},
+		{"wide", 200, 100, 0.2, 0.3, 0.5, 0.4},
+		{"tall", 100, 200, 0.2, 0.3, 0.5, 0.4},
+		{"capped", 100, 100, 0, 0, 1, 1},
+	}
+
+	for _, tt := range tests {
+		sink := newSplatSink(tt.width, tt.height)
+		sink.cfg.STROKE_SPACING = 0.1
+		in := newPointerInput(sink)
+		in.splatPointer(strokePointer(tt.x1, tt.y1, tt.x2, tt.y2))
+
+		var dx, dy float32
+		for _, s := range sink.splats {
+			dx, dy = dx+s.dx, dy+s.dy
+		}
+		force := sink.cfg.SPLAT_FORCE
+		wantX := in.correctDeltaX(tt.x2-tt.x1) * force
+		wantY := in.correctDeltaY(tt.y2-tt.y1) * force
+		if len(sink.splats) < 2 || abs(dx-wantX) > 1e-2 || abs(dy-wantY) > 1e-2 {
+			t.Errorf("%s: %d splats pushed (%g, %g), want (%g, %g)",
+				tt.name, len(sink.splats), dx, dy, wantX, wantY)
+		}
+	}
+}
+
+func TestCatmullRom(t *testing.T) {
+	p0, p1 := mgl.Vec2{0, 0}, mgl.Vec2{1, 1}
+	p2, p3 := mgl.Vec2{2, 0}, mgl.Vec2{3, 1}
+	for _, tt := range []struct {
+		t    float32
+		want mgl.Vec2
+	}{
+		{0, p1},
+		{1, p2},
+		// Symmetric about the middle of p1 and p2.
+		{0.5, mgl.Vec2{1.5, 0.5}},
+	} {
+		if got := catmullRom(p0, p1, p2, p3, tt.t); !got.ApproxEqualThreshold(tt.want, 1e-5) {
+			t.Errorf("catmullRom(t = %g) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+
+	// Evenly spaced points on a line give the line back.
+	line := func(t float32) mgl.Vec2 { return mgl.Vec2{t, 2 * t} }
+	for _, ti := range []float32{0.25, 0.5, 0.75} {
+		got := catmullRom(line(-1), line(0), line(1), line(2), ti)
+		if !got.ApproxEqualThreshold(line(ti), 1e-5) {
+			t.Errorf("catmullRom on a line at %g = %v, want %v", ti, got, line(ti))
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package fluid
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// initSunraysFramebuffers allocates the single channel sunrays target and
+// the scratch buffer its blur needs at SUNRAYS_RESOLUTION, freeing the
+// previous ones of fbos. Nothing is allocated while SUNRAYS is off.
+func (s *Simulator) initSunraysFramebuffers(fbos *framebuffers) {
+	if fbos.sunrays != nil {
+		fbos.sunrays.Delete()
+		fbos.sunraysTemp.Delete()
+	}
+	fbos.sunrays, fbos.sunraysTemp = nil, nil
+	if !s.Config.SUNRAYS {
+		return
+	}
+
+	resX, resY := getResolution(s.Config.SUNRAYS_RESOLUTION, s.width, s.height)
+	texType := uint32(gl.HALF_FLOAT)
+	rInt, r := uint32(gl.R16F), uint32(gl.RED)
+
+	fbos.sunrays = createFBO(resX, resY, rInt, r, texType, gl.LINEAR)
+	fbos.sunraysTemp = createFBO(resX, resY, rInt, r, texType, gl.LINEAR)
+}
+
+// applySunrays masks out where source has dye into mask, then blurs the
+// mask radially from the centre into destination, weighted by
+// SUNRAYS_WEIGHT.
+func (s *Simulator) applySunrays(source, mask, destination *Framebuffer) {
+	programs := s.programs
+
+	gl.Disable(gl.BLEND)
+	programs.sunraysMask.Use()
+	programs.sunraysMask.SetInt("uTexture", int32(source.attach(0)))
+	s.blit(mask)
+
+	programs.sunrays.Use()
+	programs.sunrays.SetFloat("weight", s.Config.SUNRAYS_WEIGHT)
+	programs.sunrays.SetInt("uTexture", int32(mask.attach(0)))
+	s.blit(destination)
+}
+
+// blur smooths target with a horizontal then a vertical pass through temp.
+func (s *Simulator) blur(target, temp *Framebuffer, iterations int) {
+	programs := s.programs
+
+	programs.blur.Use()
+	for i := 0; i < iterations; i++ {
+		programs.blur.SetVec2("texelSize", mgl.Vec2{target.texelSizeX, 0.0})
+		programs.blur.SetInt("uTexture", int32(target.attach(0)))
+		s.blit(temp)
+
+		programs.blur.SetVec2("texelSize", mgl.Vec2{0.0, target.texelSizeY})
+		programs.blur.SetInt("uTexture", int32(temp.attach(0)))
+		s.blit(target)
+	}
+}
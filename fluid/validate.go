@@ -0,0 +1,157 @@
+package fluid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxResolution bounds the resolution fields. The real limit is the GL
+// context's MAX_TEXTURE_SIZE, which is not known before a context exists,
+// see validateSize.
+const maxResolution = 8192
+
+// ConfigError lists every problem Validate found in a config.
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return "invalid config:\n\t" + strings.Join(e, "\n\t")
+}
+
+// Validate checks the ranges of every field and the constraints between
+// them, reporting all problems at once. Configs that fail would otherwise
+// give black output or a panic while creating framebuffers.
+func (c Config) Validate() error {
+	var errs ConfigError
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	resolutions := append(c.framebufferResolutions(),
+		namedResolution{"CAPTURE_RESOLUTION", c.CAPTURE_RESOLUTION})
+	for _, r := range resolutions {
+		check(r.value > 0 && r.value <= maxResolution,
+			"%s is %d, must be between 1 and %d", r.name, r.value, maxResolution)
+	}
+	check(c.DYE_RESOLUTION >= c.SIM_RESOLUTION,
+		"DYE_RESOLUTION (%d) must not be below SIM_RESOLUTION (%d)",
+		c.DYE_RESOLUTION, c.SIM_RESOLUTION)
+
+	check(c.DENSITY_DISSIPATION >= 0,
+		"DENSITY_DISSIPATION is %g, must not be negative", c.DENSITY_DISSIPATION)
+	check(c.VELOCITY_DISSIPATION >= 0,
+		"VELOCITY_DISSIPATION is %g, must not be negative", c.VELOCITY_DISSIPATION)
+	check(c.PRESSURE >= 0 && c.PRESSURE <= 1,
+		"PRESSURE is %g, must be between 0 and 1", c.PRESSURE)
+	check(c.PRESSURE_ITERATIONS >= 1,
+		"PRESSURE_ITERATIONS is %d, must be at least 1", c.PRESSURE_ITERATIONS)
+	check(c.CURL >= 0, "CURL is %g, must not be negative", c.CURL)
+	check(c.SPLAT_RADIUS > 0, "SPLAT_RADIUS is %g, must be positive", c.SPLAT_RADIUS)
+	check(c.SPLAT_FORCE >= 0, "SPLAT_FORCE is %g, must not be negative", c.SPLAT_FORCE)
+	check(c.COLOR_UPDATE_SPEED >= 0,
+		"COLOR_UPDATE_SPEED is %d, must not be negative", c.COLOR_UPDATE_SPEED)
+	for i, v := range c.BACK_COLOR {
+		check(v >= 0 && v <= 1,
+			"BACK_COLOR component %d is %g, must be between 0 and 1", i, v)
+	}
+
+	// Bloom and sunrays fields are only used, and their framebuffers only
+	// allocated, while the feature is on.
+	if c.BLOOM {
+		// applyBloom needs at least two levels below the bloom target,
+		// counted the way initBloomFramebuffers allocates them. Iterations
+		// past the smallest level are skipped, as in the original.
+		check(c.BLOOM_ITERATIONS >= 2,
+			"BLOOM_ITERATIONS is %d, must be at least 2", c.BLOOM_ITERATIONS)
+		if c.BLOOM_ITERATIONS >= 2 && c.BLOOM_RESOLUTION > 0 {
+			check(bloomLevels(c.BLOOM_RESOLUTION, c.BLOOM_RESOLUTION, c.BLOOM_ITERATIONS) >= 2,
+				"BLOOM_RESOLUTION is %d, must be at least 8 to halve it twice",
+				c.BLOOM_RESOLUTION)
+		}
+		check(c.BLOOM_INTENSITY >= 0,
+			"BLOOM_INTENSITY is %g, must not be negative", c.BLOOM_INTENSITY)
+		check(c.BLOOM_THRESHOLD >= 0,
+			"BLOOM_THRESHOLD is %g, must not be negative", c.BLOOM_THRESHOLD)
+		// The knee spreads BLOOM_THRESHOLD*BLOOM_SOFT_KNEE either side of
+		// the threshold, so past 1 it reaches below zero brightness and
+		// everything glows. Without a threshold there is no knee.
+		if c.BLOOM_THRESHOLD > 0 {
+			check(c.BLOOM_SOFT_KNEE >= 0 && c.BLOOM_SOFT_KNEE <= 1,
+				"BLOOM_SOFT_KNEE is %g, must be between 0 and 1 so the knee (%g) stays within BLOOM_THRESHOLD (%g)",
+				c.BLOOM_SOFT_KNEE, c.BLOOM_THRESHOLD*c.BLOOM_SOFT_KNEE, c.BLOOM_THRESHOLD)
+		}
+	}
+
+	if c.SUNRAYS {
+		check(c.SUNRAYS_WEIGHT >= 0,
+			"SUNRAYS_WEIGHT is %g, must not be negative", c.SUNRAYS_WEIGHT)
+	}
+
+	check(c.STROKE_SPACING >= 0,
+		"STROKE_SPACING is %g, must not be negative", c.STROKE_SPACING)
+
+	check(c.TOUCH_PRESSURE_CURVE > 0,
+		"TOUCH_PRESSURE_CURVE is %g, must be positive", c.TOUCH_PRESSURE_CURVE)
+	amounts := []struct {
+		name  string
+		value float32
+	}{
+		{"TOUCH_PRESSURE_RADIUS", c.TOUCH_PRESSURE_RADIUS},
+		{"TOUCH_PRESSURE_FORCE", c.TOUCH_PRESSURE_FORCE},
+		{"TOUCH_PRESSURE_DYE", c.TOUCH_PRESSURE_DYE},
+	}
+	for _, a := range amounts {
+		check(a.value >= 0 && a.value <= 1,
+			"%s is %g, must be between 0 and 1", a.name, a.value)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type namedResolution struct {
+	name  string
+	value int
+}
+
+// framebufferResolutions are the resolution fields Simulator allocates
+// framebuffers for, bloom and sunrays only while they are on.
+func (c Config) framebufferResolutions() []namedResolution {
+	resolutions := []namedResolution{
+		{"SIM_RESOLUTION", c.SIM_RESOLUTION},
+		{"DYE_RESOLUTION", c.DYE_RESOLUTION},
+	}
+	if c.BLOOM {
+		resolutions = append(resolutions,
+			namedResolution{"BLOOM_RESOLUTION", c.BLOOM_RESOLUTION})
+	}
+	if c.SUNRAYS {
+		resolutions = append(resolutions,
+			namedResolution{"SUNRAYS_RESOLUTION", c.SUNRAYS_RESOLUTION})
+	}
+	return resolutions
+}
+
+// validateSize checks the framebuffers c needs for a width x height canvas
+// against maxTextureSize, the GL context's MAX_TEXTURE_SIZE. Their long
+// side is the resolution scaled by the aspect ratio, so a resolution that
+// Validate accepts can still be too big for a wide or tall window.
+func (c Config) validateSize(width, height, maxTextureSize int) error {
+	var errs ConfigError
+	for _, r := range c.framebufferResolutions() {
+		w, h := getResolution(r.value, width, height)
+		if w > maxTextureSize || h > maxTextureSize {
+			errs = append(errs, fmt.Sprintf(
+				"%s is %d, which needs %dx%d framebuffers for a %dx%d window, the GPU allows %d a side",
+				r.name, r.value, w, h, width, height, maxTextureSize))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package fluid
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		valid  bool
+	}{
+		{"default", func(c *Config) {}, true},
+		{"no threshold", func(c *Config) { c.BLOOM_THRESHOLD = 0 }, true},
+		{"one bloom iteration", func(c *Config) { c.BLOOM_ITERATIONS = 1 }, false},
+		{"two bloom iterations", func(c *Config) { c.BLOOM_ITERATIONS = 2 }, true},
+		{"more iterations than levels", func(c *Config) { c.BLOOM_ITERATIONS = 30 }, true},
+		{"smallest bloom", func(c *Config) { c.BLOOM_RESOLUTION = 8 }, true},
+		{"bloom too small", func(c *Config) { c.BLOOM_RESOLUTION = 7 }, false},
+		{"bloom off", func(c *Config) {
+			c.BLOOM = false
+			c.BLOOM_ITERATIONS = 1
+			c.BLOOM_RESOLUTION = 0
+			c.BLOOM_SOFT_KNEE = 5
+		}, true},
+		{"knee too wide", func(c *Config) { c.BLOOM_SOFT_KNEE = 1.5 }, false},
+		{"negative knee", func(c *Config) { c.BLOOM_SOFT_KNEE = -0.1 }, false},
+		{"knee without threshold", func(c *Config) {
+			c.BLOOM_THRESHOLD = 0
+			c.BLOOM_SOFT_KNEE = 1.5
+		}, true},
+		{"no sunrays resolution", func(c *Config) { c.SUNRAYS_RESOLUTION = 0 }, false},
+		{"sunrays off", func(c *Config) {
+			c.SUNRAYS = false
+			c.SUNRAYS_RESOLUTION = 0
+			c.SUNRAYS_WEIGHT = -1
+		}, true},
+		{"dye below sim", func(c *Config) { c.DYE_RESOLUTION = 64 }, false},
+		{"negative curl", func(c *Config) { c.CURL = -1 }, false},
+	}
+
+	for _, tt := range tests {
+		c := DefaultConfig()
+		tt.change(&c)
+		if err := c.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestBloomLevels(t *testing.T) {
+	tests := []struct {
+		w, h, iterations int
+		want             int
+	}{
+		{256, 256, 8, 7},
+		{256, 256, 3, 3},
+		{512, 256, 8, 7},
+		{8, 8, 8, 2},
+		{7, 7, 8, 1},
+		{3, 3, 8, 0},
+	}
+
+	for _, tt := range tests {
+		if got := bloomLevels(tt.w, tt.h, tt.iterations); got != tt.want {
+			t.Errorf("bloomLevels(%d, %d, %d) = %d, want %d",
+				tt.w, tt.h, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		change        func(c *Config)
+		valid         bool
+	}{
+		{"square", 800, 800, func(c *Config) {}, true},
+		// DYE_RESOLUTION 1024 is 2048 texels long at 2:1.
+		{"wide", 1600, 800, func(c *Config) {}, true},
+		{"too wide", 1700, 800, func(c *Config) {}, false},
+		{"too tall", 800, 1700, func(c *Config) {}, false},
+		{"too wide for bloom", 8000, 800, func(c *Config) {
+			c.SIM_RESOLUTION, c.DYE_RESOLUTION = 128, 128
+			c.SUNRAYS = false
+			c.BLOOM_RESOLUTION = 256
+		}, false},
+		{"bloom off", 8000, 800, func(c *Config) {
+			c.SIM_RESOLUTION, c.DYE_RESOLUTION = 128, 128
+			c.SUNRAYS = false
+			c.BLOOM = false
+		}, true},
+	}
+
+	for _, tt := range tests {
+		c := DefaultConfig()
+		tt.change(&c)
+		if err := c.validateSize(tt.width, tt.height, 2048); (err == nil) != tt.valid {
+			t.Errorf("%s: validateSize() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package fluid
+
+import "fmt"
+
+// View is what Render shows: the dye, or one of the simulation fields for
+// debugging.
+type View int
+
+const (
+	ViewDye View = iota
+	ViewVelocity
+	ViewPressure
+	ViewDivergence
+	ViewCurl
+	numViews
+)
+
+var viewNames = [...]string{"dye", "velocity", "pressure", "divergence", "curl"}
+
+func (v View) String() string {
+	if v < 0 || v >= numViews {
+		return fmt.Sprintf("View(%d)", int(v))
+	}
+	return viewNames[v]
+}
+
+// Next returns the view after v, wrapping around to ViewDye.
+func (v View) Next() View {
+	return (v + 1) % numViews
+}
+
+// SetView picks what Render and Snapshot show.
+func (s *Simulator) SetView(v View) {
+	s.view = v
+}
+
+// View returns what Render shows.
+func (s *Simulator) View() View {
+	return s.view
+}
+
+// drawView draws a field with the debug program. The scales bring typical
+// values of each field to around 1.
+func (s *Simulator) drawView(target *Framebuffer) {
+	var field *Framebuffer
+	scale, vector := float32(0.05), int32(0)
+	switch s.view {
+	case ViewVelocity:
+		field, scale, vector = s.fbos.velocity.read(), 0.01, 1
+	case ViewPressure:
+		field = s.fbos.pressure.read()
+	case ViewDivergence:
+		field = s.fbos.divergence
+	case ViewCurl:
+		field, scale = s.fbos.curl, 0.01
+	}
+
+	s.debugProgram.Use()
+	s.debugProgram.SetInt("uTexture", int32(field.attach(0)))
+	s.debugProgram.SetFloat("scale", scale)
+	s.debugProgram.SetInt("vector", vector)
+	s.blit(target)
+}
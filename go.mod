@@ -0,0 +1,10 @@
+module github.com/NicholasBlaskey/go-fluid-simulation
+
+go 1.21
+
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/go-gl/gl v0.0.0-20231021071112-07e5d0ea2e71
+	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20250301202403-da16c1255728
+	github.com/go-gl/mathgl v1.2.0
+)
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+)
+
+// serveHTTP runs the control API on addr, see the README for the endpoints.
+// Handlers only decode and encode, everything touching the simulation runs
+// on the main thread through onMain.
+func serveHTTP(addr string) {
+	log.Println("http api listening on", addr)
+	if err := http.ListenAndServe(addr, newAPI(simTarget{})); err != nil {
+		log.Println("http api disabled:", err)
+	}
+}
+
+// apiTarget is what the API drives. Its methods are called on the main
+// thread only.
+type apiTarget interface {
+	config() fluid.Config
+	setConfig(config fluid.Config) error
+	multipleSplats(n int)
+	reset()
+	snapshot() image.Image
+}
+
+// simTarget is the running simulator, changing its config like a key does.
+type simTarget struct{}
+
+func (simTarget) config() fluid.Config                { return sim.Config }
+func (simTarget) setConfig(config fluid.Config) error { return setConfig(config) }
+func (simTarget) multipleSplats(n int)                { sim.MultipleSplats(n) }
+func (simTarget) reset()                              { sim.Reset() }
+func (simTarget) snapshot() image.Image               { return sim.Snapshot() }
+
+// api serves the endpoints for a target.
+type api struct {
+	target apiTarget
+}
+
+func newAPI(target apiTarget) http.Handler {
+	a := &api{target}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/config", a.handleConfig)
+	mux.HandleFunc("/api/splats", a.handleSplats)
+	mux.HandleFunc("/api/pause", a.handlePause(true))
+	mux.HandleFunc("/api/resume", a.handlePause(false))
+	mux.HandleFunc("/api/reset", a.handleReset)
+	mux.HandleFunc("/api/snapshot.png", a.handleSnapshot)
+	return mux
+}
+
+// mainTimeout is how long a request waits for the main thread.
+const mainTimeout = 5 * time.Second
+
+var errMainBusy = errors.New("the render loop is not responding, try again")
+
+// onMain runs f on the main thread and waits for it to finish. When the task
+// queue is full, or the main thread has not got to f by the time the
+// request is cancelled or mainTimeout passed, f is called off and onMain
+// returns errMainBusy. A called off f never runs.
+func onMain(r *http.Request, f func()) error {
+	var state atomic.Int32 // 0 queued, 1 running, 2 called off
+	done := make(chan struct{})
+	if !runOnMain(func() {
+		if state.CompareAndSwap(0, 1) {
+			f()
+		}
+		close(done)
+	}) {
+		return errMainBusy
+	}
+
+	timeout := time.NewTimer(mainTimeout)
+	defer timeout.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-r.Context().Done():
+	case <-timeout.C:
+	}
+	if state.CompareAndSwap(0, 2) {
+		return errMainBusy
+	}
+	// f started just in time, it has to finish before its results are used.
+	<-done
+	return nil
+}
+
+// writeBusy answers a request onMain could not run.
+func writeBusy(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusServiceUnavailable, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	allowed := strings.Join(methods, ", ")
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed,
+		fmt.Errorf("%s not allowed, use %s", r.Method, allowed))
+	return false
+}
+
+// handleConfig returns the running config on GET. PATCH takes a JSON object
+// with the fields to change and applies it like a config file edit.
+func (a *api) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPatch) {
+		return
+	}
+
+	var body []byte
+	if r.Method == http.MethodPatch {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var config fluid.Config
+	var err error
+	if busy := onMain(r, func() {
+		config = a.target.config()
+		if body == nil {
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err = dec.Decode(&config); err == nil {
+			err = a.target.setConfig(config)
+		}
+		config = a.target.config()
+	}); busy != nil {
+		writeBusy(w, busy)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+// handleSplats adds {"count": n} random splats, 5 without a body.
+func (a *api) handleSplats(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	req := struct{ Count int }{5}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Count < 0 || req.Count > 1000 {
+		writeError(w, http.StatusBadRequest,
+			fmt.Errorf("count is %d, must be between 0 and 1000", req.Count))
+		return
+	}
+
+	if err := onMain(r, func() { a.target.multipleSplats(req.Count) }); err != nil {
+		writeBusy(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+
+		var err error
+		if busy := onMain(r, func() {
+			config := a.target.config()
+			config.PAUSED = paused
+			err = a.target.setConfig(config)
+		}); busy != nil {
+			writeBusy(w, busy)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (a *api) handleReset(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	if err := onMain(r, a.target.reset); err != nil {
+		writeBusy(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSnapshot returns the current display as a PNG. Only the read back
+// happens on the main thread, the encoding does not hold up rendering.
+func (a *api) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	var img image.Image
+	if err := onMain(r, func() { img = a.target.snapshot() }); err != nil {
+		writeBusy(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+)
+
+// fakeTarget stands in for the simulator, validating configs like it does.
+type fakeTarget struct {
+	cfg    fluid.Config
+	splats int
+	resets int
+}
+
+func (f *fakeTarget) config() fluid.Config { return f.cfg }
+
+func (f *fakeTarget) setConfig(config fluid.Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	f.cfg = config
+	return nil
+}
+
+func (f *fakeTarget) multipleSplats(n int)  { f.splats += n }
+func (f *fakeTarget) reset()                { f.resets++ }
+func (f *fakeTarget) snapshot() image.Image { return image.NewRGBA(image.Rect(0, 0, 4, 4)) }
+
+// runMainLoop stands in for the render loop draining the task queue, until
+// the returned function is called.
+func runMainLoop() func() {
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				runTasks()
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+func serveAPI(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestAPIConfig(t *testing.T) {
+	defer runMainLoop()()
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		curl   float32 // CURL afterwards
+	}{
+		{"get", http.MethodGet, "", http.StatusOK, 30},
+		{"patch", http.MethodPatch, `{"CURL": 40}`, http.StatusOK, 40},
+		{"invalid", http.MethodPatch, `{"CURL": -1}`, http.StatusBadRequest, 30},
+		{"unknown field", http.MethodPatch, `{"CURLS": 40}`, http.StatusBadRequest, 30},
+		{"wrong type", http.MethodPatch, `{"CURL": "forty"}`, http.StatusBadRequest, 30},
+		{"not json", http.MethodPatch, `CURL = 40`, http.StatusBadRequest, 30},
+		{"post", http.MethodPost, `{"CURL": 40}`, http.StatusMethodNotAllowed, 30},
+	}
+
+	for _, tt := range tests {
+		target := &fakeTarget{cfg: fluid.DefaultConfig()}
+		target.cfg.CURL = 30
+		w := serveAPI(newAPI(target), tt.method, "/api/config", tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+		if target.cfg.CURL != tt.curl {
+			t.Errorf("%s: CURL %g, want %g", tt.name, target.cfg.CURL, tt.curl)
+		}
+		if tt.status == http.StatusOK && !strings.Contains(w.Body.String(), `"CURL":`) {
+			t.Errorf("%s: the config is missing from %s", tt.name, w.Body)
+		}
+	}
+}
+
+func TestAPISplats(t *testing.T) {
+	defer runMainLoop()()
+
+	tests := []struct {
+		body   string
+		status int
+		splats int
+	}{
+		{"", http.StatusNoContent, 5},
+		{`{"count": 0}`, http.StatusNoContent, 0},
+		{`{"count": 1000}`, http.StatusNoContent, 1000},
+		{`{"count": 1001}`, http.StatusBadRequest, 0},
+		{`{"count": -1}`, http.StatusBadRequest, 0},
+		{`{"count": "ten"}`, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		target := &fakeTarget{cfg: fluid.DefaultConfig()}
+		w := serveAPI(newAPI(target), http.MethodPost, "/api/splats", tt.body)
+		if w.Code != tt.status || target.splats != tt.splats {
+			t.Errorf("body %q: status %d and %d splats, want %d and %d",
+				tt.body, w.Code, target.splats, tt.status, tt.splats)
+		}
+	}
+}
+
+func TestAPIPause(t *testing.T) {
+	defer runMainLoop()()
+
+	target := &fakeTarget{cfg: fluid.DefaultConfig()}
+	handler := newAPI(target)
+	for _, step := range []struct {
+		path   string
+		paused bool
+	}{
+		{"/api/pause", true},
+		{"/api/pause", true},
+		{"/api/resume", false},
+	} {
+		if w := serveAPI(handler, http.MethodPost, step.path, ""); w.Code != http.StatusNoContent {
+			t.Errorf("%s: status %d, want %d", step.path, w.Code, http.StatusNoContent)
+		}
+		if target.cfg.PAUSED != step.paused {
+			t.Errorf("after %s PAUSED is %v, want %v", step.path, target.cfg.PAUSED, step.paused)
+		}
+	}
+	if w := serveAPI(handler, http.MethodGet, "/api/pause", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /api/pause: status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAPIMainBusy(t *testing.T) {
+	// No main loop runs, so a request gives up with its context and the
+	// reset it queued is called off.
+	target := &fakeTarget{cfg: fluid.DefaultConfig()}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/reset", nil).WithContext(ctx)
+	newAPI(target).ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	runTasks()
+	if target.resets != 0 {
+		t.Error("the called off reset ran")
+	}
+}
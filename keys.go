@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+)
+
+// action is something a key can be bound to. Actions run in the key
+// callback, on the main thread.
+type action struct {
+	name   string
+	help   string
+	run    func(w *glfw.Window)
+	repeat bool // also runs while the key is held down
+}
+
+// actions in the order the help overlay lists them.
+var actions = []action{
+	{"help", "show or hide this help", func(*glfw.Window) { showHelp = !showHelp }, false},
+	{"quit", "quit", func(w *glfw.Window) { w.SetShouldClose(true) }, false},
+	{"splats", "random splats", func(*glfw.Window) { sim.MultipleSplats(10) }, true},
+	{"pause", "pause or resume", func(*glfw.Window) {
+		toggleConfig("PAUSED", func(c *fluid.Config) *bool { return &c.PAUSED })
+	}, false},
+	{"step", "advance one frame while paused", func(*glfw.Window) { stepOnce = true }, true},
+	{"reset", "clear the canvas", func(*glfw.Window) { sim.Reset() }, false},
+	{"shading", "toggle shading", func(*glfw.Window) {
+		toggleConfig("SHADING", func(c *fluid.Config) *bool { return &c.SHADING })
+	}, false},
+	{"bloom", "toggle bloom", func(*glfw.Window) {
+		toggleConfig("BLOOM", func(c *fluid.Config) *bool { return &c.BLOOM })
+	}, false},
+	{"sunrays", "toggle sunrays", func(*glfw.Window) {
+		toggleConfig("SUNRAYS", func(c *fluid.Config) *bool { return &c.SUNRAYS })
+	}, false},
+	{"preset", "next preset", func(*glfw.Window) { cyclePreset() }, false},
+	{"view", "next debug view", func(*glfw.Window) {
+		sim.SetView(sim.View().Next())
+		log.Println("view", sim.View())
+	}, false},
+	{"screenshot", "save a screenshot", func(*glfw.Window) { screenshot() }, false},
+	{"record", "start or stop recording", func(*glfw.Window) { toggleRecording() }, false},
+}
+
+var defaultKeys = map[string]string{
+	"help":       "H",
+	"quit":       "Escape",
+	"splats":     "Space",
+	"pause":      "Enter",
+	"step":       "Right",
+	"reset":      "R",
+	"shading":    "S",
+	"bloom":      "B",
+	"sunrays":    "U",
+	"preset":     "P",
+	"view":       "V",
+	"screenshot": "F12",
+	"record":     "F9",
+}
+
+// keyNames are the keys bindings can use besides letters and digits.
+var keyNames = map[string]glfw.Key{
+	"Space": glfw.KeySpace, "Enter": glfw.KeyEnter, "Escape": glfw.KeyEscape,
+	"Tab": glfw.KeyTab, "Backspace": glfw.KeyBackspace,
+	"Insert": glfw.KeyInsert, "Delete": glfw.KeyDelete,
+	"Left": glfw.KeyLeft, "Right": glfw.KeyRight,
+	"Up": glfw.KeyUp, "Down": glfw.KeyDown,
+	"Home": glfw.KeyHome, "End": glfw.KeyEnd,
+	"PageUp": glfw.KeyPageUp, "PageDown": glfw.KeyPageDown,
+	"Comma": glfw.KeyComma, "Period": glfw.KeyPeriod, "Slash": glfw.KeySlash,
+	"Minus": glfw.KeyMinus, "Equal": glfw.KeyEqual,
+	"F1": glfw.KeyF1, "F2": glfw.KeyF2, "F3": glfw.KeyF3, "F4": glfw.KeyF4,
+	"F5": glfw.KeyF5, "F6": glfw.KeyF6, "F7": glfw.KeyF7, "F8": glfw.KeyF8,
+	"F9": glfw.KeyF9, "F10": glfw.KeyF10, "F11": glfw.KeyF11, "F12": glfw.KeyF12,
+}
+
+// parseKey accepts a letter, a digit or one of keyNames, ignoring case.
+func parseKey(name string) (glfw.Key, error) {
+	if len(name) == 1 {
+		c := strings.ToUpper(name)[0]
+		if c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			// GLFW uses ASCII for these.
+			return glfw.Key(c), nil
+		}
+	}
+	for keyName, key := range keyNames {
+		if strings.EqualFold(keyName, name) {
+			return key, nil
+		}
+	}
+	return glfw.KeyUnknown, fmt.Errorf("unknown key %q", name)
+}
+
+// keymap binds keys to actions, and remembers the key names for the help.
+type keymap struct {
+	actions map[glfw.Key]*action
+	names   map[string]string // action name to key name
+}
+
+// loadKeymap starts from defaultKeys and applies the bindings of a JSON or
+// TOML file of action = "key" lines, if path is set. An empty key unbinds
+// the action.
+func loadKeymap(path string) (*keymap, error) {
+	names := map[string]string{}
+	for name, key := range defaultKeys {
+		names[name] = key
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		overrides := map[string]string{}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			err = json.Unmarshal(data, &overrides)
+		case ".toml":
+			_, err = toml.Decode(string(data), &overrides)
+		default:
+			err = fmt.Errorf("unknown keymap format %q, want .json or .toml",
+				filepath.Ext(path))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for name, key := range overrides {
+			if _, ok := defaultKeys[name]; !ok {
+				return nil, fmt.Errorf("%s: unknown action %q", path, name)
+			}
+			names[name] = key
+		}
+	}
+
+	km := &keymap{actions: map[glfw.Key]*action{}, names: names}
+	for i := range actions {
+		a := &actions[i]
+		if names[a.name] == "" {
+			continue
+		}
+		key, err := parseKey(names[a.name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", a.name, err)
+		}
+		if other, ok := km.actions[key]; ok {
+			return nil, fmt.Errorf("%s is bound to both %s and %s",
+				names[a.name], other.name, a.name)
+		}
+		km.actions[key] = a
+	}
+
+	return km, nil
+}
+
+// helpLines lists the bindings, one per line.
+func (km *keymap) helpLines() []string {
+	width := 0
+	for _, key := range km.names {
+		if len(key) > width {
+			width = len(key)
+		}
+	}
+
+	lines := []string{}
+	for _, a := range actions {
+		if key := km.names[a.name]; key != "" {
+			lines = append(lines, fmt.Sprintf("%-*s  %s", width, key, a.help))
+		}
+	}
+	return lines
+}
+
+func toggleConfig(name string, field func(c *fluid.Config) *bool) {
+	config := sim.Config
+	*field(&config) = !*field(&config)
+	if err := setConfig(config); err != nil {
+		log.Println(name, "rejected:", err)
+		return
+	}
+	log.Println(name, *field(&config))
+}
+
+func screenshot() {
+	path := fmt.Sprintf("screenshot-%s.png", time.Now().Format("20060102-150405"))
+	if err := writePNG(path, sim); err != nil {
+		log.Println("screenshot:", err)
+		return
+	}
+	log.Println("screenshot written to", path)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want glfw.Key // KeyUnknown for an error
+	}{
+		{"K", glfw.KeyK},
+		{"k", glfw.KeyK},
+		{"7", glfw.Key7},
+		{"Space", glfw.KeySpace},
+		{"pagedown", glfw.KeyPageDown},
+		{"F12", glfw.KeyF12},
+		{"", glfw.KeyUnknown},
+		{"F13", glfw.KeyUnknown},
+		{"Ctrl+K", glfw.KeyUnknown},
+		{"KK", glfw.KeyUnknown},
+		{"#", glfw.KeyUnknown},
+	}
+
+	for _, tt := range tests {
+		key, err := parseKey(tt.name)
+		if tt.want == glfw.KeyUnknown {
+			if err == nil {
+				t.Errorf("parseKey(%q) = %v, want an error", tt.name, key)
+			}
+			continue
+		}
+		if err != nil || key != tt.want {
+			t.Errorf("parseKey(%q) = %v, %v, want %v", tt.name, key, err, tt.want)
+		}
+	}
+}
+
+func TestLoadKeymap(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // written to name, none when empty
+		err     string // part of the error, none when empty
+		bound   map[glfw.Key]string
+		unbound []glfw.Key
+	}{
+		{name: "defaults", bound: map[glfw.Key]string{
+			glfw.KeyH: "help", glfw.KeyEnter: "pause", glfw.KeyF9: "record"}},
+		{name: "keys.toml", file: "pause = \"K\"\nquit = \"\"\n",
+			bound:   map[glfw.Key]string{glfw.KeyK: "pause"},
+			unbound: []glfw.Key{glfw.KeyEnter, glfw.KeyEscape}},
+		{name: "keys.json", file: `{"screenshot": "f5"}`,
+			bound:   map[glfw.Key]string{glfw.KeyF5: "screenshot", glfw.KeyH: "help"},
+			unbound: []glfw.Key{glfw.KeyF12}},
+		// Moving an action onto a key another action keeps is a clash.
+		{name: "duplicate.toml", file: `pause = "H"`, err: "H is bound to both help and pause"},
+		{name: "duplicate2.toml", file: "pause = \"K\"\nreset = \"k\"\n", err: "bound to both"},
+		// Swapping two keys is not.
+		{name: "swap.toml", file: "pause = \"R\"\nreset = \"Enter\"\n",
+			bound: map[glfw.Key]string{glfw.KeyR: "pause", glfw.KeyEnter: "reset"}},
+		{name: "action.toml", file: `jump = "J"`, err: `unknown action "jump"`},
+		{name: "key.toml", file: `pause = "Ctrl+P"`, err: `pause: unknown key "Ctrl+P"`},
+		{name: "keys.yaml", file: `pause: K`, err: "unknown keymap format"},
+		{name: "broken.toml", file: `pause = `, err: "broken.toml"},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := ""
+		if tt.file != "" {
+			path = filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		km, err := loadKeymap(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want one with %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for key, name := range tt.bound {
+			if a := km.actions[key]; a == nil || a.name != name {
+				t.Errorf("%s: key %v runs %v, want %s", tt.name, key, a, name)
+			}
+		}
+		for _, key := range tt.unbound {
+			if a := km.actions[key]; a != nil {
+				t.Errorf("%s: key %v runs %s, want nothing", tt.name, key, a.name)
+			}
+		}
+	}
+
+	if _, err := loadKeymap(filepath.Join(dir, "missing.toml")); err == nil {
+		t.Error("loadKeymap of a missing file succeeded")
+	}
+}
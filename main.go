@@ -1,36 +1,25 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
-	"math"
 	"math/rand"
+	"os"
 	"runtime"
+	"sync/atomic"
 	"time"
-	"unsafe"
-
-	"bufio"
-	"io"
-	"os/exec"
-	"strconv"
-	"strings"
-
-	"image"
-	"image/draw"
-	_ "image/png"
-	"os"
-
-	mgl "github.com/go-gl/mathgl/mgl32"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
 )
 
 func init() {
 	runtime.LockOSThread()
 }
 
-// We will move everything to its own module later on
 // Create window
 func initGLFW(windowTitle string, width, height int) *glfw.Window {
 	if err := glfw.Init(); err != nil {
@@ -52,7 +41,8 @@ func initGLFW(windowTitle string, width, height int) *glfw.Window {
 		glfw.FramebufferSizeCallback(framebuffer_size_callback))
 	window.SetKeyCallback(keyCallback)
 
-	//	window.SetCursorPosCallback(glfw.CursorPosCallback(mouse_callback))
+	window.SetCursorPosCallback(cursorPosCallback)
+	window.SetMouseButtonCallback(mouseButtonCallback)
 
 	if err := gl.Init(); err != nil {
 		panic(err)
@@ -62,848 +52,97 @@ func initGLFW(windowTitle string, width, height int) *glfw.Window {
 }
 
 func framebuffer_size_callback(w *glfw.Window, widthParam int, heightParam int) {
-	width = widthParam
-	height = heightParam
-	fbos = initFramebuffers(fbos)
+	sim.Resize(widthParam, heightParam)
 }
 
 func keyCallback(window *glfw.Window, key glfw.Key, scancode int,
 	action glfw.Action, mods glfw.ModifierKey) {
 
-	if key == glfw.KeyEscape && action == glfw.Press {
-		window.SetShouldClose(true)
+	a, ok := keys.actions[key]
+	if !ok {
+		return
 	}
-
-	if key == glfw.KeySpace {
-		if action == glfw.Press || action == glfw.Repeat {
-			multipleSplats(programs, fbos, 10)
-		}
+	if action == glfw.Press || action == glfw.Repeat && a.repeat {
+		a.run(window)
 	}
 }
 
-// Set parameters (Perhaps better to var this but will keep same name for now)
-// Actually move to its own module later on
-var config = struct {
-	SIM_RESOLUTION       int
-	DYE_RESOLUTION       int
-	CAPTURE_RESOLUTION   int
-	DENSITY_DISSIPATION  float32
-	VELOCITY_DISSIPATION float32
-	PRESSURE             float32
-	PRESSURE_ITERATIONS  int
-	CURL                 float32
-	SPLAT_RADIUS         float32
-	SPLAT_FORCE          float32
-	SHADING              bool
-	COLORFUL             bool
-	COLOR_UPDATE_SPEED   int
-	PAUSED               bool
-	BACK_COLOR           mgl.Vec3
-	TRANSPARENT          bool
-	BLOOM                bool
-	BLOOM_ITERATIONS     int
-	BLOOM_RESOLUTION     int
-	BLOOM_INTENSITY      float32
-	BLOOM_THRESHOLD      float32
-	BLOOM_SOFT_KNEE      float32
-	SUNRAYS              bool
-	SUNRAYS_RESOLUTION   int
-	SUNRAYS_WEIGHT       float32
-}{
-	SIM_RESOLUTION:       256, //512,
-	DYE_RESOLUTION:       1024,
-	CAPTURE_RESOLUTION:   512,
-	DENSITY_DISSIPATION:  1.0, //1.0,
-	VELOCITY_DISSIPATION: 0.5, //0.0
-	PRESSURE:             0.8,
-	PRESSURE_ITERATIONS:  50, //20,
-	CURL:                 30.0,
-	SPLAT_RADIUS:         0.50, //0.85,
-	SPLAT_FORCE:          7000, //6000
-	SHADING:              true,
-	COLORFUL:             true,
-	COLOR_UPDATE_SPEED:   10,
-	PAUSED:               false,
-	BACK_COLOR:           mgl.Vec3{0, 0, 0},
-	TRANSPARENT:          false,
-	BLOOM:                true,
-	BLOOM_ITERATIONS:     8,
-	BLOOM_RESOLUTION:     256,
-	BLOOM_INTENSITY:      0.8,
-	BLOOM_THRESHOLD:      0.6,
-	BLOOM_SOFT_KNEE:      0.7,
-	SUNRAYS:              true,
-	SUNRAYS_RESOLUTION:   196,
-	SUNRAYS_WEIGHT:       1.0,
-}
-
-// Material
-type material struct {
-	vertexSource   string
-	fragmentSource string
-	activeProgram  *Shader
-	uniforms       []string
-	origFragSource string // Source stored with no flags enabled
-}
-
-func newMaterial(vsSource, fsSource string) *material {
-	return &material{vsSource, fsSource, nil, []string{}, fsSource}
-}
-
-func (m *material) setKeywords(keywords []string) {
-	// Simplying here because we are gonna assume we arent gonna change params
-	// much if all at compile time so we can take the preformance hit of
-	// recompling programs.
-	m.fragmentSource = m.origFragSource
-	for _, keyword := range keywords {
-		m.fragmentSource = "#define " + keyword + "\n" + m.fragmentSource
+// cyclePreset applies the next preset, keeping the fields changed while
+// running. A preset that is rejected is not selected, the current one stays
+// in effect for reloads.
+func cyclePreset() {
+	preset, name := options.nextPreset()
+	if preset < 0 {
+		return
 	}
-
-	m.activeProgram = MakeShaders(m.vertexSource, m.fragmentSource)
-	// TODO uniforms
-}
-
-func (m *material) bind() {
-	m.activeProgram.Use()
-}
-
-type Shader struct {
-	ID uint32
-}
-
-func (s *Shader) SetVec4(name string, value mgl.Vec4) {
-	gl.Uniform4fv(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")),
-		1, &value[0])
-}
-
-func (s Shader) SetInt(name string, value int32) {
-	gl.Uniform1i(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")), value)
-}
-
-func (s Shader) SetFloat(name string, value float32) {
-	gl.Uniform1f(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")), value)
-}
-
-func (s Shader) SetVec2(name string, value mgl.Vec2) {
-	gl.Uniform2fv(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")),
-		1, &value[0])
-}
-
-func (s Shader) SetVec3(name string, value mgl.Vec3) {
-	gl.Uniform3fv(gl.GetUniformLocation(s.ID, gl.Str(name+"\x00")),
-		1, &value[0])
-}
-
-func MakeShaders(vertexCode, fragmentCode string) *Shader {
-	// Compile the shaders
-	vertexShader := gl.CreateShader(gl.VERTEX_SHADER)
-	shaderSource, freeVertex := gl.Strs(vertexCode + "\x00")
-	defer freeVertex()
-	gl.ShaderSource(vertexShader, 1, shaderSource, nil)
-	gl.CompileShader(vertexShader)
-	checkCompileErrors(vertexShader, "VERTEX", vertexCode)
-
-	fragmentShader := gl.CreateShader(gl.FRAGMENT_SHADER)
-	shaderSource, freeFragment := gl.Strs(fragmentCode + "\x00")
-	defer freeFragment()
-	gl.ShaderSource(fragmentShader, 1, shaderSource, nil)
-	gl.CompileShader(fragmentShader)
-	checkCompileErrors(fragmentShader, "FRAGMENT", fragmentCode)
-
-	// Create a shader program
-	ID := gl.CreateProgram()
-	gl.AttachShader(ID, vertexShader)
-	gl.AttachShader(ID, fragmentShader)
-	gl.LinkProgram(ID)
-
-	checkCompileErrors(ID, "PROGRAM", "")
-
-	// Delete shaders
-	gl.DeleteShader(vertexShader)
-	gl.DeleteShader(fragmentShader)
-
-	return &Shader{ID: ID}
-}
-
-func checkCompileErrors(shader uint32, shaderType, source string) {
-	var success int32
-	var infoLog [1024]byte
-
-	var status uint32 = gl.COMPILE_STATUS
-	stageMessage := "Shader_Compilation_error"
-	errorFunc := gl.GetShaderInfoLog
-	getIV := gl.GetShaderiv
-	if shaderType == "PROGRAM" {
-		status = gl.LINK_STATUS
-		stageMessage = "Program_link_error"
-		errorFunc = gl.GetProgramInfoLog
-		getIV = gl.GetProgramiv
+	config, err := options.build(preset)
+	if err == nil {
+		err = sim.SetConfig(config)
 	}
-
-	getIV(shader, status, &success)
-	if success != 1 {
-		test := &success
-		errorFunc(shader, 1024, test, (*uint8)(unsafe.Pointer(&infoLog)))
-		log.Fatalln("!!!!" + source + stageMessage + shaderType + "|" + string(infoLog[:1024]) + "|")
-	}
-}
-
-func (s Shader) Use() {
-	gl.UseProgram(s.ID)
-}
-
-type shaders struct {
-	curl             *Shader
-	vorticity        *Shader
-	divergence       *Shader
-	clear            *Shader
-	pressure         *Shader
-	gradientSubtract *Shader
-	advection        *Shader
-	color            *Shader
-	display          *Shader
-	splat            *Shader
-}
-
-const baseVertexShader = `
-    #version 410 core
-    precision highp float;
-
-    layout (location = 0) in vec2 aPosition;
-
-    out highp vec2 vUv;
-    out highp vec2 vL;
-    out highp vec2 vR;
-    out highp vec2 vT;
-    out highp vec2 vB;
-
-    uniform highp vec2 texelSize;    
-    //out highp vec2 texelSize;
-
-    void main () {
-        vUv = aPosition * 0.5 + 0.5;
-        vL = vUv - vec2(texelSize.x, 0.0);
-        vR = vUv + vec2(texelSize.x, 0.0);
-        vT = vUv + vec2(0.0, texelSize.y);
-        vB = vUv - vec2(0.0, texelSize.y);
-        gl_Position = vec4(aPosition, 0.0, 1.0);
-    }
-`
-
-const curlShader = `
-    #version 410 core
-
-    precision mediump float;
-    precision mediump sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    in highp vec2 vL;
-    in highp vec2 vR;
-    in highp vec2 vT;
-    in highp vec2 vB;
-    uniform sampler2D uVelocity;
-
-    void main () {
-        float L = texture2D(uVelocity, vL).y;
-        float R = texture2D(uVelocity, vR).y;
-        float T = texture2D(uVelocity, vT).x;
-        float B = texture2D(uVelocity, vB).x;
-        float vorticity = R - L - T + B;
-        FragColor = vec4(0.5 * vorticity, 0.0, 0.0, 1.0);
-    }
-`
-
-const vorticityShader = `
-    #version 410 core
- 
-    precision highp float;
-    precision highp sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    in highp vec2 vL;
-    in highp vec2 vR;
-    in highp vec2 vT;
-    in highp vec2 vB;
-
-    uniform sampler2D uVelocity;
-    uniform sampler2D uCurl;
-    uniform float curl;
-    uniform float dt;
-
-    void main () {
-        float L = texture2D(uCurl, vL).x;
-        float R = texture2D(uCurl, vR).x;
-        float T = texture2D(uCurl, vT).x;
-        float B = texture2D(uCurl, vB).x;
-        float C = texture2D(uCurl, vUv).x;
-
-        vec2 force = 0.5 * vec2(abs(T) - abs(B), abs(R) - abs(L));
-        force /= length(force) + 0.0001;
-        force *= curl * C;
-        force.y *= -1.0;
-
-        vec2 velocity = texture2D(uVelocity, vUv).xy;
-        velocity += force * dt;
-        velocity = min(max(velocity, -1000.0), 1000.0);
-        FragColor = vec4(velocity, 0.0, 1.0);
-    }
-`
-
-const divergenceShader = `
-    #version 410 core
-
-    precision mediump float;
-    precision mediump sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    in highp vec2 vL;
-    in highp vec2 vR;
-    in highp vec2 vT;
-    in highp vec2 vB;
-    uniform sampler2D uVelocity;
-
-    void main () {
-        float L = texture2D(uVelocity, vL).x;
-        float R = texture2D(uVelocity, vR).x;
-        float T = texture2D(uVelocity, vT).y;
-        float B = texture2D(uVelocity, vB).y;
-
-        vec2 C = texture2D(uVelocity, vUv).xy;
-        if (vL.x < 0.0) { L = -C.x; }
-        if (vR.x > 1.0) { R = -C.x; }
-        if (vT.y > 1.0) { T = -C.y; }
-        if (vB.y < 0.0) { B = -C.y; }
-
-        float div = 0.5 * (R - L + T - B);
-        FragColor = vec4(div, 0.0, 0.0, 1.0);
-    }
-`
-
-const clearShader = `
-    #version 410 core
-
-    precision mediump float;
-    precision mediump sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    uniform sampler2D uTexture;
-    uniform float value;
-
-    void main () {
-        FragColor = value * texture2D(uTexture, vUv);
-    }
-`
-const copyShader = `
-    #version 410 core
-
-    precision mediump float;
-    precision mediump sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    uniform sampler2D uTexture;
-
-    void main () {
-        FragColor = texture2D(uTexture, vUv);
-    }
-`
-
-const pressureShader = `
-    #version 410 core
-
-    precision mediump float;
-    precision mediump sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    in highp vec2 vL;
-    in highp vec2 vR;
-    in highp vec2 vT;
-    in highp vec2 vB;
-    uniform sampler2D uPressure;
-    uniform sampler2D uDivergence;
-
-    void main () {
-        float L = texture2D(uPressure, vL).x;
-        float R = texture2D(uPressure, vR).x;
-        float T = texture2D(uPressure, vT).x;
-        float B = texture2D(uPressure, vB).x;
-        float C = texture2D(uPressure, vUv).x;
-        float divergence = texture2D(uDivergence, vUv).x;
-        float pressure = (L + R + B + T - divergence) * 0.25;
-        FragColor = vec4(pressure, 0.0, 0.0, 1.0);
-    }
-`
-
-const gradientSubtractShader = `
-    #version 410 core
-
-    precision mediump float;
-    precision mediump sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    in highp vec2 vL;
-    in highp vec2 vR;
-    in highp vec2 vT;
-    in highp vec2 vB;
-    uniform sampler2D uPressure;
-    uniform sampler2D uVelocity;
-
-    void main () {
-        float L = texture2D(uPressure, vL).x;
-        float R = texture2D(uPressure, vR).x;
-        float T = texture2D(uPressure, vT).x;
-        float B = texture2D(uPressure, vB).x;
-        vec2 velocity = texture2D(uVelocity, vUv).xy;
-        velocity.xy -= vec2(R - L, T - B);
-        FragColor = vec4(velocity, 0.0, 1.0);
-    }
-`
-
-const advectionShader = `
-    #version 410 core
-
-    precision highp float;
-    precision highp sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    uniform sampler2D uVelocity;
-    uniform sampler2D uSource;
-    uniform vec2 texelSize;
-    uniform vec2 dyeTexelSize;
-    uniform float dt;
-    uniform float dissipation;
-
-    vec4 bilerp (sampler2D sam, vec2 uv, vec2 tsize) {
-        vec2 st = uv / tsize - 0.5;
-
-        vec2 iuv = floor(st);
-        vec2 fuv = fract(st);
-
-        vec4 a = texture2D(sam, (iuv + vec2(0.5, 0.5)) * tsize);
-        vec4 b = texture2D(sam, (iuv + vec2(1.5, 0.5)) * tsize);
-        vec4 c = texture2D(sam, (iuv + vec2(0.5, 1.5)) * tsize);
-        vec4 d = texture2D(sam, (iuv + vec2(1.5, 1.5)) * tsize);
-
-        return mix(mix(a, b, fuv.x), mix(c, d, fuv.x), fuv.y);
-    }
-
-    void main () {
-    #ifdef MANUAL_FILTERING
-        vec2 coord = vUv - dt * bilerp(uVelocity, vUv, texelSize).xy * texelSize;
-        vec4 result = bilerp(uSource, coord, dyeTexelSize);
-    #else
-        vec2 coord = vUv - dt * texture2D(uVelocity, vUv).xy * texelSize;
-        vec4 result = texture2D(uSource, coord);
-    #endif
-        float decay = 1.0 + dissipation * dt;
-        FragColor = result / decay;
-    }
-`
-
-const colorShader = `
-    #version 410 core
-
-    precision mediump float;
-
-    out vec4 FragColor;
-
-    uniform vec4 color;
-
-    void main () {
-        FragColor = color;
-    }
-`
-
-const displayShader = `
-    #version 410 core
-
-    precision highp float;
-    precision highp sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    in highp vec2 vL;
-    in highp vec2 vR;
-    in highp vec2 vT;
-    in highp vec2 vB;
-    uniform sampler2D uTexture;
-    uniform sampler2D uBloom;
-    uniform sampler2D uSunrays;
-    uniform sampler2D uDithering;
-    uniform vec2 ditherScale;
-    uniform vec2 texelSize;
-
-    vec3 linearToGamma (vec3 color) {
-        color = max(color, vec3(0));
-        return max(1.055 * pow(color, vec3(0.416666667)) - 0.055, vec3(0));
-    }
-
-    void main () {
-        vec3 c = texture2D(uTexture, vUv).rgb;
-
-    #ifdef SHADING
-        vec3 lc = texture2D(uTexture, vL).rgb;
-        vec3 rc = texture2D(uTexture, vR).rgb;
-        vec3 tc = texture2D(uTexture, vT).rgb;
-        vec3 bc = texture2D(uTexture, vB).rgb;
-
-        float dx = length(rc) - length(lc);
-        float dy = length(tc) - length(bc);
-
-        vec3 n = normalize(vec3(dx, dy, length(texelSize)));
-        vec3 l = vec3(0.0, 0.0, 1.0);
-
-        float diffuse = clamp(dot(n, l) + 0.7, 0.7, 1.0);
-        c *= diffuse;
-    #endif
-
-    #ifdef BLOOM
-        vec3 bloom = texture2D(uBloom, vUv).rgb;
-    #endif
-
-    #ifdef SUNRAYS
-        float sunrays = texture2D(uSunrays, vUv).r;
-        c *= sunrays;
-    #ifdef BLOOM
-        bloom *= sunrays;
-    #endif
-    #endif
-
-    #ifdef BLOOM
-        float noise = texture2D(uDithering, vUv * ditherScale).r;
-        noise = noise * 2.0 - 1.0;
-        bloom += noise / 255.0;
-        bloom = linearToGamma(bloom);
-        c += bloom;
-    #endif
-
-        float a = max(c.r, max(c.g, c.b));
-        FragColor = vec4(c, a);
-    }
-`
-
-// Used in adding dye and motion to simulation
-const splatShader = `
-    #version 410 core
-
-    precision highp float;
-    precision highp sampler2D;
-
-    out vec4 FragColor;
-
-    in highp vec2 vUv;
-    uniform sampler2D uTarget;
-    uniform float aspectRatio;
-    uniform vec3 color;
-    uniform vec2 point;
-    uniform float radius;
-
-    void main () {
-        vec2 p = vUv - point.xy;
-        p.x *= aspectRatio;
-        vec3 splat = exp(-dot(p, p) / radius) * color;
-        vec3 base = texture2D(uTarget, vUv).xyz;
-        FragColor = vec4(base + splat, 1.0);
-    }
-`
-
-// Create framebuffers
-type framebuffers struct {
-	dye        *doubleFramebuffer
-	velocity   *doubleFramebuffer
-	divergence *framebuffer
-	curl       *framebuffer
-	pressure   *doubleFramebuffer
-}
-
-type framebuffer struct {
-	texture    uint32
-	fbo        uint32
-	width      int
-	height     int
-	texelSizeX float32
-	texelSizeY float32
-}
-
-func (f *framebuffer) attach(id uint32) uint32 {
-	gl.ActiveTexture(gl.TEXTURE0 + id)
-	gl.BindTexture(gl.TEXTURE_2D, f.texture)
-
-	return id
-}
-
-type doubleFramebuffer struct {
-	width      int
-	height     int
-	texelSizeX float32
-	texelSizeY float32
-	fbo1       *framebuffer
-	fbo2       *framebuffer
-}
-
-func (df *doubleFramebuffer) read() *framebuffer {
-	return df.fbo1
-}
-
-func (df *doubleFramebuffer) write() *framebuffer {
-	return df.fbo2
-}
-
-func (df *doubleFramebuffer) writeB(f *framebuffer) {
-	df.fbo2 = f
-}
-
-func (df *doubleFramebuffer) swap() {
-	temp := df.fbo1
-	df.fbo1 = df.fbo2
-	df.fbo2 = temp
-}
-
-func initFramebuffers(fbos *framebuffers) *framebuffers {
-	simResX, simResY := getResolution(config.SIM_RESOLUTION)
-	dyeResX, dyeResY := getResolution(config.DYE_RESOLUTION)
-
-	texType := uint32(gl.HALF_FLOAT)
-	rgbaInt, rgba := uint32(gl.RGBA16F), uint32(gl.RGBA)
-	rgInt, rg := uint32(gl.RG16F), uint32(gl.RG)
-	rInt, r := uint32(gl.R16F), uint32(gl.RED)
-	filtering := int32(gl.LINEAR)
-
-	gl.Disable(gl.BLEND)
-
-	var dye, velocity *doubleFramebuffer
-	if fbos != nil {
-		dye = resizeDoubleFBO(fbos.dye, dyeResX, dyeResY, rgbaInt,
-			rgba, texType, filtering)
-		velocity = resizeDoubleFBO(fbos.velocity, simResX, simResY,
-			rgInt, rg, texType, filtering)
-	} else {
-		dye = createDoubleFBO(dyeResX, dyeResY, rgbaInt, rgba, texType, filtering)
-		velocity = createDoubleFBO(simResX, simResY, rgInt, rg, texType, filtering)
-	}
-
-	divergence := createFBO(simResX, simResY, rInt, r, texType, gl.NEAREST)
-	curl := createFBO(simResX, simResY, rInt, r, texType, gl.NEAREST)
-	pressure := createDoubleFBO(simResX, simResY, rInt, r, texType, gl.NEAREST)
-
-	return &framebuffers{dye, velocity, divergence, curl, pressure}
-}
-
-func createFBO(w, h int, internalFormat, format, texType uint32, param int32) *framebuffer {
-	var texture uint32
-	gl.GenTextures(1, &texture)
-	gl.BindTexture(gl.TEXTURE_2D, texture)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, param)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, param)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, int32(internalFormat), int32(w), int32(h),
-		0, format, texType, gl.Ptr(nil))
-	// 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0,
-	//      gl.TEXTURE_2D, textureColorbuffer, 0)
-
-	var fbo uint32
-	gl.GenFramebuffers(1, &fbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0,
-		gl.TEXTURE_2D, texture, 0)
-	gl.Viewport(0, 0, int32(w), int32(h))
-	gl.Clear(gl.COLOR_BUFFER_BIT)
-
-	// TODO check
-	if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
-		panic(gl.CheckFramebufferStatus(gl.FRAMEBUFFER))
-		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	}
-
-	log.Println("FBO text", texture)
-
-	return &framebuffer{texture, fbo, w, h, 1.0 / float32(w), 1.0 / float32(h)}
-}
-
-func createDoubleFBO(w, h int, internalFormat, format,
-	texType uint32, param int32) *doubleFramebuffer {
-
-	fbo1 := createFBO(w, h, internalFormat, format, texType, param)
-	fbo2 := createFBO(w, h, internalFormat, format, texType, param)
-
-	return &doubleFramebuffer{w, h, fbo1.texelSizeX, fbo2.texelSizeY, fbo1, fbo2}
-}
-
-func resizeFBO(target *framebuffer, w, h int, internalFormat, format,
-	texType uint32, param int32) *framebuffer {
-
-	newFBO := createFBO(w, h, internalFormat, format, texType, param)
-	copyProgram.Use()
-	copyProgram.SetInt("uTexture", int32(target.attach(0)))
-	blit(newFBO)
-
-	return newFBO
-}
-
-func resizeDoubleFBO(target *doubleFramebuffer, w, h int, internalFormat,
-	format, texType uint32, param int32) *doubleFramebuffer {
-
-	if target.width == w && target.height == h {
-		return target
+	if err != nil {
+		log.Println("preset", name, "rejected:", err)
+		return
 	}
-	target.fbo1 = resizeFBO(target.read(), w, h, internalFormat,
-		format, texType, param)
-	target.writeB(createFBO(w, h, internalFormat, format, texType, param))
-	target.width = w
-	target.height = h
-	target.texelSizeX = 1.0 / float32(w)
-	target.texelSizeY = 1.0 / float32(h)
-
-	return target
+	options.selectPreset(preset)
+	log.Println("preset", name)
 }
 
-func getResolution(resolution int) (int, int) {
-	aspectRatio := float32(width) / float32(height)
-	if aspectRatio < 1.0 {
-		aspectRatio = 1.0 / aspectRatio
-	}
-
-	min := int(resolution)
-	max := int(float32(resolution) * aspectRatio)
-
-	if width > height {
-		return max, height
+// setConfig applies a change made while running, from a key, OSC or HTTP,
+// and remembers the changed fields so they survive config reloads and
+// presets.
+func setConfig(config fluid.Config) error {
+	old := sim.Config
+	if err := sim.SetConfig(config); err != nil {
+		return err
 	}
-	return min, max
-}
-
-// Load in dithering texture
-type texture struct {
-	texture uint32
-	width   int32
-	height  int32
+	options.keep(old, config)
+	return nil
 }
 
-func (t *texture) attach(id uint32) uint32 {
-	gl.ActiveTexture(gl.TEXTURE0 + id)
-	gl.BindTexture(gl.TEXTURE_2D, t.texture)
-
-	return id
-}
-
-func createTexture(path string) *texture {
-	var textureID uint32
-	gl.GenTextures(1, &textureID)
-	gl.BindTexture(gl.TEXTURE_2D, textureID)
-
-	file, err := os.Open(path)
-	if err != nil {
-		panic(err)
-	}
-	defer file.Close()
+func update(sim *fluid.Simulator, lastUpdateTime float32) float32 {
+	dt, lastUpdateTime := calcDeltaTime(lastUpdateTime)
 
-	img, _, err := image.Decode(file)
-	if err != nil {
-		panic(err)
-	}
+	// TODO resize
+	// TODO inputs (or maybe not)
 
-	data := image.NewRGBA(img.Bounds())
-	if data.Stride != data.Rect.Size().X*4 {
-		panic("Unsupported stride")
+	// While paused the solver stands still, but input is still applied and
+	// the display drawn. A frame advance runs one step with the dt of the
+	// last one, and a replay only moves on with the steps so it stays in
+	// sync with the fluid. Pointer colours change with the steps too, so
+	// how long a replay sat paused does not matter.
+	stepping := !sim.Config.PAUSED || stepOnce
+	stepOnce = false
+	if sim.Config.PAUSED {
+		dt = lastDt
+	}
+
+	if replay != nil && stepping {
+		if replayDt, ok := replay.Advance(sim); ok {
+			dt = replayDt
+		} else {
+			if err := replay.Err(); err != nil {
+				log.Println(err)
+			}
+			log.Println("replay finished")
+			replay = nil
+		}
 	}
-	draw.Draw(data, data.Bounds(), img, image.Point{0, 0}, draw.Src)
-
-	width, height := int32(data.Rect.Size().X), int32(data.Rect.Size().Y)
-	gl.BindTexture(gl.TEXTURE_2D, textureID)
-	gl.TexImage2D(
-		gl.TEXTURE_2D,
-		0,
-		gl.RGBA,
-		width,
-		height,
-		0,
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(data.Pix))
-	gl.GenerateMipmap(gl.TEXTURE_2D)
-
-	// Set texture parameters for wrapping
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER,
-		gl.LINEAR_MIPMAP_LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-
-	return &texture{textureID, width, height}
-}
-
-// Render function
-func initBlit() {
-	var VAO, VBO, EBO uint32
-	gl.GenVertexArrays(1, &VAO)
-	gl.GenBuffers(1, &VBO)
-	gl.GenBuffers(1, &EBO)
-
-	gl.BindVertexArray(VAO)
 
-	vertices := []float32{-1, -1, -1, 1, 1, 1, 1, -1}
-	gl.BindBuffer(gl.ARRAY_BUFFER, VBO)
-	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4,
-		gl.Ptr(vertices), gl.STATIC_DRAW)
-	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 0, gl.PtrOffset(0)) // is the 0 right? No offset?
-	gl.EnableVertexAttribArray(0)
-
-	eboVertices := []uint16{0, 1, 2, 0, 2, 3}
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, EBO)
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(eboVertices)*2,
-		gl.Ptr(eboVertices), gl.STATIC_DRAW)
-}
+	sim.ApplyInputs()
 
-func blit(target *framebuffer) {
-	if target == nil {
-		gl.Viewport(0, 0, int32(width), int32(height))
-		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	} else {
-		gl.Viewport(0, 0, int32(target.width), int32(target.height))
-		gl.BindFramebuffer(gl.FRAMEBUFFER, target.fbo)
+	if stepping {
+		sim.UpdateColors(dt)
+		sim.Step(dt)
+		lastDt = dt
 	}
+	sim.Render(nil)
 
-	/*
-		if clear {
-			gl.ClearColor(0.0, 0.0, 0.0, 1.0)
-			gl.Clear(gl.COLOR_BUFFER_BIT)
+	if showHelp {
+		if helpOverlay == nil {
+			helpOverlay = fluid.NewOverlay(renderText(keys.helpLines(), 2))
 		}
-	*/
-
-	// gl.BindVertexArray(VAO)
-	gl.DrawElements(gl.TRIANGLES, 6, gl.UNSIGNED_SHORT, gl.PtrOffset(0))
-}
-
-func update(programs *shaders, fbos *framebuffers,
-	displayMaterial *material, lastUpdateTime float32) float32 {
-
-	dt, lastUpdateTime := calcDeltaTime(lastUpdateTime)
-
-	// TODO resize
-	//updateColors(dt)
-	// TODO inputs (or maybe not)
-
-	applyInputs(programs, fbos)
-
-	step(programs, fbos, dt)
-	render(programs, fbos, displayMaterial)
+		sim.RenderOverlay(helpOverlay, nil, 10, 10)
+	}
 
 	return lastUpdateTime
 }
@@ -918,315 +157,206 @@ func calcDeltaTime(lastUpdateTime float32) (float32, float32) {
 	return dt, now
 }
 
-type inPointer struct {
-	texcoordX     float32
-	texcoordY     float32
-	prevTexcoordX float32
-	prevTexcoordY float32
-	deltaX        float32
-	deltaY        float32
-	moved         bool
-	color         mgl.Vec3
-}
-
-var pointer = &inPointer{0, 0, 0, 0, 0, 0, false, mgl.Vec3{0.10, 0.10, 0.05}}
+// The mouse gets its own pointer, touch devices use IDs from 0 up.
+const mousePointerID = -1
 
-/*
-func mouse_callback(w *glfw.Window, xPos float64, yPos float64) {
-	fmt.Println(xPos, yPos)
-	updatePointerMoveData(pointer, float32(xPos), float32(yPos))
-}
-*/
+// mouseDown is only touched by the GLFW callbacks, which run on the main
+// thread.
+var mouseDown = false
 
-const (
-	touchXMax = 3840
-	touchYMax = 1932
-)
-
-func readTouchPad(inputNum string) {
-	cmd := exec.Command("evtest")
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		panic(err)
-	}
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		panic(err)
-	}
+func mouseButtonCallback(w *glfw.Window, button glfw.MouseButton,
+	action glfw.Action, mods glfw.ModifierKey) {
 
-	if err := cmd.Start(); err != nil {
-		panic(err)
+	if button != glfw.MouseButtonLeft || replay != nil {
+		return
 	}
-	r := bufio.NewReader(stdout)
-	//go func() {
-	io.WriteString(stdin, inputNum)
-	stdin.Close()
-	//}()
-
-	x, y := -1, -1
-	//i := 0
-	for {
-		in, _ := r.ReadString('\n')
-
-		if strings.Contains(in, "(ABS_X),") {
-			split := strings.Split(in, " ")
-			x, _ = strconv.Atoi(strings.Trim(split[len(split)-1], "\n "))
-		} else if strings.Contains(in, "(ABS_Y),") {
-			split := strings.Split(in, " ")
-			y, _ = strconv.Atoi(strings.Trim(split[len(split)-1], "\n "))
-		}
-
-		if x == -1 || y == -1 {
-			continue
-		}
 
-		//i += 1
-		//if i == 10 {
-		updatePointerMoveData(pointer, (float32(x)/touchXMax)*float32(width),
-			(float32(y)/touchYMax)*float32(height))
-		//	i = 0
-		//}
-		x, y = -1, -1
+	xPos, yPos := w.GetCursorPos()
+	switch action {
+	case glfw.Press:
+		mouseDown = true
+		sim.PushPointerEvent(mouseEvent(w, fluid.PointerDown, xPos, yPos))
+	case glfw.Release:
+		mouseDown = false
+		sim.PushPointerEvent(mouseEvent(w, fluid.PointerUp, xPos, yPos))
 	}
 }
 
-func updatePointerMoveData(pointer *inPointer, posX, posY float32) {
-	pointer.prevTexcoordX = pointer.texcoordX
-	pointer.prevTexcoordY = pointer.texcoordY
-	pointer.texcoordX = posX / float32(width)
-	pointer.texcoordY = 1.0 - posY/float32(height)
-	pointer.deltaX = correctDeltaX(pointer.texcoordX -
-		pointer.prevTexcoordX)
-	pointer.deltaY = correctDeltaY(pointer.texcoordY -
-		pointer.prevTexcoordY)
-
-	pointer.moved = math.Abs(float64(pointer.deltaX)) > 0.0 ||
-		math.Abs(float64(pointer.deltaY)) > 0.0
-}
-
-func correctDeltaX(delta float32) float32 {
-	aspectRatio := float32(width) / float32(height)
-	if aspectRatio < 1 {
-		delta *= aspectRatio
+func cursorPosCallback(w *glfw.Window, xPos float64, yPos float64) {
+	if !mouseDown || replay != nil {
+		return
 	}
-	return delta
-}
 
-func correctDeltaY(delta float32) float32 {
-	aspectRatio := float32(width) / float32(height)
-	if aspectRatio < 1 {
-		delta /= aspectRatio
-	}
-	return delta
+	sim.PushPointerEvent(mouseEvent(w, fluid.PointerMove, xPos, yPos))
 }
 
-func applyInputs(programs *shaders, fbos *framebuffers) {
-	if pointer.moved {
-		pointer.moved = false
+// mouseEvent normalizes a cursor position by the window size, which unlike
+// the framebuffer size is in the same screen coordinates on high DPI
+// displays.
+func mouseEvent(w *glfw.Window, typ fluid.PointerEventType,
+	xPos, yPos float64) fluid.PointerEvent {
 
-		brightScale := float32(1.0)
-
-		dx := pointer.deltaX * config.SPLAT_FORCE
-		dy := pointer.deltaY * config.SPLAT_FORCE
-		splat(programs, fbos, pointer.texcoordX,
-			pointer.texcoordY, dx, dy,
-			mgl.Vec3{rand.Float32() * brightScale,
-				rand.Float32() * brightScale,
-				rand.Float32() * brightScale})
-		//mgl.Vec3{rand.Float32() * brightScale * 0.3,
-		//	rand.Float32() * brightScale * 0.5,
-		//	rand.Float32() * brightScale * 0.3}) //pointer.color)
+	ev := fluid.PointerEvent{Time: time.Now(), Type: typ, ID: mousePointerID}
+	winWidth, winHeight := w.GetSize()
+	if winWidth > 0 && winHeight > 0 {
+		ev.X = float32(xPos / float64(winWidth))
+		ev.Y = float32(yPos / float64(winHeight))
 	}
-}
 
-func render(programs *shaders, fbos *framebuffers, displayMaterial *material) {
-	gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
-	gl.Enable(gl.BLEND)
-
-	drawColor(programs, mgl.Vec4{0.0, 0.0, 0.0, 1.0})
-	drawDisplay(displayMaterial, fbos)
+	return ev
 }
 
-func drawColor(programs *shaders, col mgl.Vec4) {
-	programs.color.Use()
-	programs.color.SetVec4("color", col)
-	blit(nil)
-}
-
-func drawDisplay(displayMaterial *material, fbos *framebuffers) {
-	displayMaterial.bind()
-
-	//log.Println(fbos.dye.read().attach(0), int32(fbos.dye.read().attach(0)))
-	displayMaterial.activeProgram.SetInt("uTexture",
-		int32(fbos.dye.read().attach(0)))
-	blit(nil)
-}
-
-// Step function
-func step(programs *shaders, fbos *framebuffers, dt float32) {
-	texelSize := mgl.Vec2{fbos.velocity.texelSizeX, fbos.velocity.texelSizeY}
-
-	gl.Disable(gl.BLEND)
-
-	programs.curl.Use()
-	programs.curl.SetVec2("texelSize", texelSize)
-	programs.curl.SetInt("uVelocity", int32(fbos.velocity.read().attach(0)))
-	blit(fbos.curl)
-
-	programs.vorticity.Use()
-	programs.vorticity.SetVec2("texelSize", texelSize)
-	programs.vorticity.SetInt("uVelocity", int32(fbos.velocity.read().attach(0)))
-	programs.vorticity.SetInt("uCurl", int32(fbos.curl.attach(1)))
-	programs.vorticity.SetFloat("curl", config.CURL)
-	programs.vorticity.SetFloat("dt", dt)
-	blit(fbos.velocity.write())
-	fbos.velocity.swap()
-
-	programs.divergence.Use()
-	programs.divergence.SetVec2("texelSize", texelSize)
-	programs.divergence.SetInt("uVelocity", int32(fbos.velocity.read().attach(0)))
-	blit(fbos.divergence)
-
-	programs.clear.Use()
-	programs.clear.SetInt("uTexture", int32(fbos.pressure.read().attach(0)))
-	programs.clear.SetFloat("value", config.PRESSURE)
-	blit(fbos.pressure.write())
-	fbos.pressure.swap()
+// tasks holds work other goroutines need done on the main thread, which owns
+// the GL context. It is drained once a frame.
+var (
+	tasks        = make(chan func(), 256)
+	droppedTasks atomic.Int64 // tasks runOnMain had no room for
+)
 
-	programs.pressure.Use()
-	programs.pressure.SetVec2("texelSize", texelSize)
-	programs.pressure.SetInt("uDivergence", int32(fbos.divergence.attach(0)))
-	for i := 0; i < config.PRESSURE_ITERATIONS; i++ {
-		programs.pressure.SetInt("uPressure", int32(fbos.pressure.read().attach(1)))
-		blit(fbos.pressure.write())
-		fbos.pressure.swap()
+// runOnMain queues f for the start of the next frame. It is safe to call
+// from any goroutine and never blocks: while the queue is full f is dropped,
+// counted for runTasks to log, and runOnMain reports false.
+func runOnMain(f func()) bool {
+	select {
+	case tasks <- f:
+		return true
+	default:
+		droppedTasks.Add(1)
+		return false
 	}
-
-	programs.gradientSubtract.Use()
-	programs.gradientSubtract.SetVec2("texelSize", texelSize)
-	programs.gradientSubtract.SetInt("uPressure", int32(fbos.pressure.read().attach(0)))
-	programs.gradientSubtract.SetInt("uVelocity", int32(fbos.velocity.read().attach(1)))
-	blit(fbos.velocity.write())
-	fbos.velocity.swap()
-
-	programs.advection.Use()
-	programs.advection.SetVec2("texelSize", texelSize)
-	// TODO not support linear but we should be good
-	velocityID := int32(fbos.velocity.read().attach(0))
-	programs.advection.SetInt("uVelocity", velocityID)
-	programs.advection.SetInt("uSource", velocityID)
-	programs.advection.SetFloat("dt", dt)
-	programs.advection.SetFloat("dissipation", config.VELOCITY_DISSIPATION)
-	blit(fbos.velocity.write())
-	fbos.velocity.swap()
-
-	// TODO not support linear filtering
-	programs.advection.SetInt("uVelocity", int32(fbos.velocity.read().attach(0)))
-	programs.advection.SetInt("uSource", int32(fbos.dye.read().attach(1)))
-	programs.advection.SetFloat("dissipation", config.DENSITY_DISSIPATION)
-	blit(fbos.dye.write())
-	fbos.dye.swap()
 }
 
-// Splats
-func multipleSplats(programs *shaders, fbos *framebuffers, n int) {
-	/*
-		cols := []mgl.Vec3{
-			mgl.Vec3{0.9, 0.3, 0.3},
-			mgl.Vec3{0.3, 0.9, 0.3},
-			mgl.Vec3{0.5, 0.5, 0.9},
-		}
-	*/
-
-	//for _, col := range cols {
-	for i := 0; i < n; i++ {
-		x := rand.Float32()
-		y := rand.Float32()
-		dx := 1000.0 * (rand.Float32() - 0.5)
-		dy := 1000.0 * (rand.Float32() - 0.5)
-		splat(programs, fbos, x, y, dx, dy,
-			mgl.Vec3{rand.Float32(), rand.Float32(), rand.Float32()})
+func runTasks() {
+	if n := droppedTasks.Swap(0); n > 0 {
+		log.Println("main thread busy, dropped", n, "tasks")
 	}
-}
-
-func splat(programs *shaders, fbos *framebuffers,
-	x, y, dx, dy float32, col mgl.Vec3) {
-
-	programs.splat.Use()
-	programs.splat.SetInt("uTarget", int32(fbos.velocity.read().attach(0)))
-	programs.splat.SetFloat("aspectRatio", float32(width)/float32(height))
-	programs.splat.SetVec2("point", mgl.Vec2{x, y})
-	programs.splat.SetVec3("color", mgl.Vec3{dx, dy, 0.0})
-	programs.splat.SetFloat("radius", correctRadius(config.SPLAT_RADIUS/100.0))
-	blit(fbos.velocity.write())
-	fbos.velocity.swap()
-
-	programs.splat.SetInt("uTarget", int32(fbos.dye.read().attach(0)))
-	programs.splat.SetVec3("color", col)
-	blit(fbos.dye.write())
-	fbos.dye.swap()
-}
-
-func correctRadius(r float32) float32 {
-	aspectRatio := float32(width) / float32(height)
-	if aspectRatio > 1 {
-		r *= aspectRatio
+	for n := len(tasks); n > 0; n-- {
+		(<-tasks)()
 	}
-	return r
 }
 
 var (
-	programs    *shaders      = nil
-	fbos        *framebuffers = nil
-	width                     = 512 //1920 //512
-	height                    = 512 //1080 //512
-	copyProgram *Shader       = nil
+	sim     *fluid.Simulator = nil
+	options *configOptions   = nil
+	replay  *fluid.Replay    = nil
+	keys    *keymap          = nil
+
+	// Set by key actions, read by update.
+	stepOnce    = false
+	lastDt      = float32(0.016666)
+	showHelp    = false
+	helpOverlay *fluid.Overlay
+	width       = 512 //1920 //512
+	height      = 512 //1080 //512
 )
 
 // Run simulation
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		runRender(os.Args[2:])
+		return
+	}
+
+	touchpad := flag.String("touchpad", "",
+		"touch device to read, a /dev/input path or part of its name, "+
+			"\"none\" to disable (default: first touchpad found)")
+	oscAddr := flag.String("osc", "",
+		"UDP address to accept OSC messages on, e.g. :9000")
+	httpAddr := flag.String("http", "",
+		"TCP address to serve the JSON control API on, e.g. localhost:8080")
+	record := flag.String("record", "",
+		"write the input session to this file")
+	replayPath := flag.String("replay", "",
+		"replay a session written by -record instead of reading input")
+	keysPath := flag.String("keys", "",
+		"JSON or TOML file binding actions to keys, e.g. pause = \"P\"")
+	options = addConfigFlags(flag.CommandLine)
+	flag.Parse()
+	config, err := options.load()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if keys, err = loadKeymap(*keysPath); err != nil {
+		log.Fatalln(err)
+	}
+
+	seed := time.Now().UTC().UnixNano()
+	if *replayPath != "" {
+		file, err := os.Open(*replayPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer file.Close()
+		if replay, err = fluid.NewReplay(file); err != nil {
+			log.Fatalln(err)
+		}
+		seed = replay.Seed
+	}
+
 	window := initGLFW("Fluid sim", width, height)
-	_ = window
 
-	rand.Seed(time.Now().UTC().UnixNano())
+	rand.Seed(seed)
 
-	initBlit()
-	copyProgram = MakeShaders(baseVertexShader, copyShader)
-	programs = &shaders{
-		MakeShaders(baseVertexShader, curlShader),
-		MakeShaders(baseVertexShader, vorticityShader),
-		MakeShaders(baseVertexShader, divergenceShader),
-		MakeShaders(baseVertexShader, clearShader),
-		MakeShaders(baseVertexShader, pressureShader),
-		MakeShaders(baseVertexShader, gradientSubtractShader),
-		MakeShaders(baseVertexShader, advectionShader),
-		MakeShaders(baseVertexShader, colorShader),
-		MakeShaders(baseVertexShader, displayShader),
-		MakeShaders(baseVertexShader, splatShader),
+	sim = fluid.NewSimulator(width, height, config)
+
+	if *record != "" {
+		if err := startRecording(*record, seed); err != nil {
+			log.Fatalln(err)
+		}
 	}
-	fbos = initFramebuffers(nil)
-	displayMaterial := newMaterial(baseVertexShader, displayShader)
-	displayMaterial.setKeywords([]string{})
+	defer stopRecording()
 
-	for i := 0; i < 5; i++ {
-		multipleSplats(programs, fbos, 3)
+	// A replay brings its own random splats.
+	if replay == nil {
+		for i := 0; i < 5; i++ {
+			sim.MultipleSplats(3)
+		}
 	}
 
-	go readTouchPad("8") // change here
+	if *touchpad != "none" && replay == nil {
+		go readTouchPad(*touchpad)
+	}
+
+	if *oscAddr != "" {
+		go listenOSC(*oscAddr)
+	}
+	if *httpAddr != "" {
+		go serveHTTP(*httpAddr)
+	}
+
+	var reloads <-chan fluid.Config
+	if *options.path != "" {
+		reloads = watchConfig(options, 500*time.Millisecond)
+	}
 
 	lastTime := 0.0
 	numFrames := 0.0
 	prev := float32(glfw.GetTime())
 	i := 0
 	for !window.ShouldClose() {
-		lastTime, numFrames = DisplayFrameRate(window, "", numFrames, lastTime)
+		title := ""
+		if sim.Config.PAUSED {
+			title = "paused"
+		}
+		lastTime, numFrames = DisplayFrameRate(window, title, numFrames, lastTime)
 		i += 1
 
-		if i%1000 == 0 {
-			multipleSplats(programs, fbos, 3)
+		if i%1000 == 0 && replay == nil {
+			sim.MultipleSplats(3)
+		}
+
+		select {
+		case config := <-reloads:
+			if err := sim.SetConfig(config); err != nil {
+				log.Println("config edit rejected:", err)
+			} else {
+				log.Println("config reloaded from", *options.path)
+			}
+		default:
 		}
 
-		prev = update(programs, fbos, displayMaterial, prev)
+		runTasks()
+		prev = update(sim, prev)
 
 		time.Sleep(time.Millisecond * 0) //time.Millisecond * 250)
 
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+)
+
+// configOptions are the flags every mode uses to build its fluid.Config.
+// The config is the defaults, then the -config file, then the selected
+// preset, then the command line flags and finally the fields changed while
+// running, until they are edited in the file.
+type configOptions struct {
+	path       *string
+	dump       *string
+	presetName *string
+	presetDir  *string
+	fields     *fluid.ConfigFlags
+
+	// The preset and runtime fields can be changed from the render loop
+	// while the config watcher reloads in the background.
+	mu      sync.Mutex
+	presets []fluid.Preset
+	preset  int
+	runtime map[string]string // fields set by keys, OSC or HTTP
+	file    map[string]string // fields as the file had them when last loaded
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configOptions {
+	return &configOptions{
+		path: fs.String("config", "",
+			"JSON or TOML file to load the config from"),
+		dump: fs.String("dump-config", "",
+			"write the final config to this JSON or TOML file"),
+		presetName: fs.String("preset", "",
+			"preset applied on top of the config file, e.g. thick-smoke, thin-ink, swirls, screensaver"),
+		presetDir: fs.String("presets", "",
+			"directory of extra JSON or TOML presets"),
+		fields:  fluid.NewConfigFlags(fs),
+		preset:  -1,
+		runtime: map[string]string{},
+		file:    map[string]string{},
+	}
+}
+
+// load applies the config file and then the command line on top of the
+// defaults, writing the result out if -dump-config was given.
+func (o *configOptions) load() (fluid.Config, error) {
+	presets, err := fluid.LoadPresets(*o.presetDir)
+	if err != nil {
+		return fluid.DefaultConfig(), err
+	}
+	o.presets = presets
+	if *o.presetName != "" {
+		if o.preset = fluid.FindPreset(presets, *o.presetName); o.preset < 0 {
+			return fluid.DefaultConfig(), fmt.Errorf("unknown preset %q", *o.presetName)
+		}
+	}
+
+	config, err := o.reload()
+	if err != nil {
+		return config, err
+	}
+
+	if *o.dump != "" {
+		if err := config.Save(*o.dump); err != nil {
+			return config, err
+		}
+		log.Println("config written to", *o.dump)
+	}
+
+	return config, nil
+}
+
+// reload builds the config again from the file, the preset and the command
+// line and validates the result.
+func (o *configOptions) reload() (fluid.Config, error) {
+	o.mu.Lock()
+	preset := o.preset
+	o.mu.Unlock()
+
+	return o.build(preset)
+}
+
+// build makes the config with the given preset, -1 for none, in place of
+// the selected one.
+func (o *configOptions) build(preset int) (fluid.Config, error) {
+	config := fluid.DefaultConfig()
+	if *o.path != "" {
+		var err error
+		if config, err = fluid.LoadConfig(*o.path); err != nil {
+			return config, err
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if *o.path != "" {
+		o.forgetEdited(config)
+	}
+	if preset >= 0 {
+		var err error
+		if config, err = o.presets[preset].Apply(config); err != nil {
+			return config, err
+		}
+	}
+
+	if err := o.fields.Apply(&config); err != nil {
+		return config, err
+	}
+	for name, value := range o.runtime {
+		if err := config.Set(name, value); err != nil {
+			return config, err
+		}
+	}
+
+	return config, config.Validate()
+}
+
+// forgetEdited drops the runtime value of every field whose value in the
+// config file changed since it was last loaded, so editing the file wins
+// over an earlier change from a key, OSC or HTTP.
+func (o *configOptions) forgetEdited(file fluid.Config) {
+	for _, name := range fluid.ConfigFields() {
+		value, _ := file.Get(name)
+		last, loaded := o.file[name]
+		o.file[name] = value
+		if _, set := o.runtime[name]; set && loaded && value != last {
+			log.Println(name, "edited in", *o.path+", replacing the value set while running")
+			delete(o.runtime, name)
+		}
+	}
+}
+
+// keep records the fields that differ between old and config, so that
+// reloads and presets apply underneath changes made while running.
+func (o *configOptions) keep(old, config fluid.Config) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, name := range fluid.ConfigFields() {
+		before, _ := old.Get(name)
+		after, _ := config.Get(name)
+		if before != after {
+			o.runtime[name] = after
+		}
+	}
+}
+
+// nextPreset returns the index and name of the preset after the selected
+// one, or -1 if there are none.
+func (o *configOptions) nextPreset() (int, string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.presets) == 0 {
+		return -1, ""
+	}
+	preset := (o.preset + 1) % len(o.presets)
+	return preset, o.presets[preset].Name
+}
+
+// selectPreset makes reloads apply preset.
+func (o *configOptions) selectPreset(preset int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.preset = preset
+}
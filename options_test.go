@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRuntimeFieldsAndFileEdits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("CURL = 10\nSPLAT_FORCE = 100\n")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := addConfigFlags(fs)
+	if err := fs.Parse([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	config, err := o.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A key pauses and OSC changes CURL.
+	changed := config
+	changed.PAUSED = true
+	changed.CURL = 20
+	o.keep(config, changed)
+
+	reload := func() (curl, force float32, paused bool) {
+		t.Helper()
+		config, err := o.reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config.CURL, config.SPLAT_FORCE, config.PAUSED
+	}
+
+	if curl, force, paused := reload(); curl != 20 || force != 100 || !paused {
+		t.Errorf("reload undid runtime changes: CURL %v, SPLAT_FORCE %v, PAUSED %v",
+			curl, force, paused)
+	}
+
+	// Editing another field leaves the runtime ones alone.
+	write("CURL = 10\nSPLAT_FORCE = 200\n")
+	if curl, force, paused := reload(); curl != 20 || force != 200 || !paused {
+		t.Errorf("after editing SPLAT_FORCE: CURL %v, SPLAT_FORCE %v, PAUSED %v",
+			curl, force, paused)
+	}
+
+	// Editing CURL in the file hands it back to the file for good. PAUSED
+	// is written with the value it already had, which is no edit.
+	write("CURL = 15\nSPLAT_FORCE = 200\nPAUSED = false\n")
+	if curl, _, paused := reload(); curl != 15 || !paused {
+		t.Errorf("after editing CURL: CURL %v, PAUSED %v", curl, paused)
+	}
+	write("CURL = 16\nSPLAT_FORCE = 200\nPAUSED = true\n")
+	if curl, _, paused := reload(); curl != 16 || !paused {
+		t.Errorf("second edit: CURL %v, PAUSED %v", curl, paused)
+	}
+	write("CURL = 16\nSPLAT_FORCE = 200\nPAUSED = false\n")
+	if _, _, paused := reload(); paused {
+		t.Error("unpausing in the file did not unpause")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+	"github.com/NicholasBlaskey/go-fluid-simulation/osc"
+)
+
+// OSC pointers get IDs of their own so they never steal a finger's pointer.
+const oscPointerBase = 1 << 20
+
+// pointerQueue takes pointer events from other goroutines, like
+// fluid.Simulator.PushPointerEvent.
+type pointerQueue interface {
+	PushPointerEvent(ev fluid.PointerEvent)
+}
+
+// oscControl takes the splat and config messages from the OSC goroutine.
+// Neither may block it.
+type oscControl interface {
+	splat(x, y, dx, dy float32, col mgl.Vec3)
+	setConfig(name, value string)
+}
+
+// mainControl hands OSC splats and config changes to the main thread,
+// dropping them while its task queue is full.
+type mainControl struct{}
+
+func (mainControl) splat(x, y, dx, dy float32, col mgl.Vec3) {
+	runOnMain(func() { sim.Splat(x, y, dx, dy, col) })
+}
+
+func (mainControl) setConfig(name, value string) {
+	runOnMain(func() {
+		config := sim.Config
+		err := config.Set(name, value)
+		if err == nil {
+			err = setConfig(config)
+		}
+		if err != nil {
+			log.Println("osc: /fluid/config/"+name, err)
+		}
+	})
+}
+
+// listenOSC accepts OSC packets on the UDP address addr:
+//
+//	/fluid/splat x y dx dy r g b   a splat, as fluid.Simulator.Splat
+//	/fluid/pointer id x y          moves pointer id, x and y in [0, 1] from
+//	                               the top left
+//	/fluid/pointer/up id           ends the stroke of pointer id
+//	/fluid/config/NAME value...    sets a config field, as with its flag
+func listenOSC(addr string) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		log.Println("osc disabled:", err)
+		return
+	}
+	defer conn.Close()
+	log.Println("osc listening on", conn.LocalAddr())
+
+	serveOSC(conn, sim, mainControl{})
+}
+
+// serveOSC handles the packets arriving on conn until it is closed, queueing
+// pointer events on pointers and passing splats and config changes to
+// control. Malformed packets are logged and skipped.
+func serveOSC(conn net.PacketConn, pointers pointerQueue, control oscControl) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Println("osc:", err)
+			return
+		}
+
+		messages, err := osc.Parse(buf[:n])
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, m := range messages {
+			if err := handleOSC(m, pointers, control); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+func handleOSC(m osc.Message, pointers pointerQueue, control oscControl) error {
+	switch {
+	case m.Address == "/fluid/splat":
+		var v [7]float32
+		for i := range v {
+			var err error
+			if v[i], err = m.Float32(i); err != nil {
+				return err
+			}
+		}
+		control.splat(v[0], v[1], v[2], v[3], mgl.Vec3{v[4], v[5], v[6]})
+
+	case m.Address == "/fluid/pointer":
+		id, err := m.Int(0)
+		if err != nil {
+			return err
+		}
+		ev := fluid.PointerEvent{Time: time.Now(), Type: fluid.PointerMove,
+			ID: oscPointerBase + id}
+		if ev.X, err = m.Float32(1); err != nil {
+			return err
+		}
+		if ev.Y, err = m.Float32(2); err != nil {
+			return err
+		}
+		pointers.PushPointerEvent(ev)
+
+	case m.Address == "/fluid/pointer/up":
+		id, err := m.Int(0)
+		if err != nil {
+			return err
+		}
+		pointers.PushPointerEvent(fluid.PointerEvent{Time: time.Now(),
+			Type: fluid.PointerUp, ID: oscPointerBase + id})
+
+	case strings.HasPrefix(m.Address, "/fluid/config/"):
+		name := strings.TrimPrefix(m.Address, "/fluid/config/")
+		values := make([]string, len(m.Args))
+		for i, arg := range m.Args {
+			values[i] = fmt.Sprint(arg)
+		}
+		control.setConfig(name, strings.Join(values, ","))
+
+	default:
+		return fmt.Errorf("osc: unknown address %s", m.Address)
+	}
+
+	return nil
+}
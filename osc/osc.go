@@ -0,0 +1,229 @@
+// Package osc reads and writes Open Sound Control 1.0 packets, the UDP
+// protocol TouchDesigner, Max and most show control tools speak.
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Message is one OSC message. Args hold int32, float32, string, []byte
+// (blobs) and bool values.
+type Message struct {
+	Address string
+	Args    []interface{}
+}
+
+// Parse decodes a packet, a single message or a bundle. Bundles are
+// flattened in order and their time tags ignored, everything applies
+// straight away.
+func Parse(packet []byte) ([]Message, error) {
+	if bytes.HasPrefix(packet, []byte("#bundle\x00")) {
+		return parseBundle(packet)
+	}
+
+	m, err := parseMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	return []Message{m}, nil
+}
+
+func parseBundle(packet []byte) ([]Message, error) {
+	// "#bundle\0" and the 8 byte time tag.
+	if len(packet) < 16 {
+		return nil, fmt.Errorf("osc: bundle header is %d bytes, want 16", len(packet))
+	}
+	rest := packet[16:]
+
+	var messages []Message
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, fmt.Errorf("osc: truncated bundle element size")
+		}
+		size := binary.BigEndian.Uint32(rest)
+		rest = rest[4:]
+		if size%4 != 0 || uint32(len(rest)) < size {
+			return nil, fmt.Errorf("osc: bad bundle element size %d", size)
+		}
+
+		elements, err := Parse(rest[:size])
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, elements...)
+		rest = rest[size:]
+	}
+
+	return messages, nil
+}
+
+func parseMessage(packet []byte) (Message, error) {
+	var m Message
+	address, rest, err := readString(packet)
+	if err != nil {
+		return m, err
+	}
+	if len(address) == 0 || address[0] != '/' {
+		return m, fmt.Errorf("osc: bad address %q", address)
+	}
+	m.Address = address
+
+	// Some old senders leave out the type tags for messages without args.
+	if len(rest) == 0 {
+		return m, nil
+	}
+	tags, rest, err := readString(rest)
+	if err != nil {
+		return m, err
+	}
+	if len(tags) == 0 || tags[0] != ',' {
+		return m, fmt.Errorf("osc: %s: bad type tags %q", address, tags)
+	}
+
+	for _, tag := range tags[1:] {
+		var arg interface{}
+		switch tag {
+		case 'i', 'f':
+			if len(rest) < 4 {
+				return m, fmt.Errorf("osc: %s: truncated argument", address)
+			}
+			bits := binary.BigEndian.Uint32(rest)
+			rest = rest[4:]
+			if tag == 'i' {
+				arg = int32(bits)
+			} else {
+				arg = math.Float32frombits(bits)
+			}
+		case 's':
+			arg, rest, err = readString(rest)
+		case 'b':
+			arg, rest, err = readBlob(rest)
+		case 'T', 'F':
+			arg = tag == 'T'
+		default:
+			return m, fmt.Errorf("osc: %s: unsupported type tag %q", address, tag)
+		}
+		if err != nil {
+			return m, fmt.Errorf("osc: %s: %v", address, err)
+		}
+		m.Args = append(m.Args, arg)
+	}
+
+	return m, nil
+}
+
+// readString reads a null terminated string padded to 4 bytes.
+func readString(b []byte) (string, []byte, error) {
+	end := bytes.IndexByte(b, 0)
+	if end < 0 {
+		return "", nil, fmt.Errorf("osc: unterminated string")
+	}
+	padded := pad(end + 1)
+	if padded > len(b) {
+		return "", nil, fmt.Errorf("osc: truncated string")
+	}
+	return string(b[:end]), b[padded:], nil
+}
+
+func readBlob(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, fmt.Errorf("osc: truncated blob size")
+	}
+	size := int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	if size < 0 || pad(size) > len(b) {
+		return nil, nil, fmt.Errorf("osc: truncated blob")
+	}
+	return b[:size], b[pad(size):], nil
+}
+
+func pad(n int) int {
+	return (n + 3) &^ 3
+}
+
+// MarshalBinary encodes the message. Go ints and float64s are sent as
+// int32 and float32.
+func (m Message) MarshalBinary() ([]byte, error) {
+	tags := []byte{','}
+	var args bytes.Buffer
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case int:
+			tags = append(tags, 'i')
+			binary.Write(&args, binary.BigEndian, int32(v))
+		case int32:
+			tags = append(tags, 'i')
+			binary.Write(&args, binary.BigEndian, v)
+		case float64:
+			tags = append(tags, 'f')
+			binary.Write(&args, binary.BigEndian, float32(v))
+		case float32:
+			tags = append(tags, 'f')
+			binary.Write(&args, binary.BigEndian, v)
+		case string:
+			tags = append(tags, 's')
+			writeString(&args, v)
+		case []byte:
+			tags = append(tags, 'b')
+			binary.Write(&args, binary.BigEndian, int32(len(v)))
+			args.Write(v)
+			args.Write(make([]byte, pad(len(v))-len(v)))
+		case bool:
+			if v {
+				tags = append(tags, 'T')
+			} else {
+				tags = append(tags, 'F')
+			}
+		default:
+			return nil, fmt.Errorf("osc: %s: cannot encode %T", m.Address, arg)
+		}
+	}
+
+	var packet bytes.Buffer
+	writeString(&packet, m.Address)
+	writeString(&packet, string(tags))
+	packet.Write(args.Bytes())
+	return packet.Bytes(), nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+	buf.Write(make([]byte, pad(len(s)+1)-len(s)))
+}
+
+// Float32 returns argument i as a number, accepting ints as well since
+// senders differ in what they use.
+func (m Message) Float32(i int) (float32, error) {
+	if i >= len(m.Args) {
+		return 0, fmt.Errorf("osc: %s: missing argument %d", m.Address, i+1)
+	}
+	switch v := m.Args[i].(type) {
+	case float32:
+		return v, nil
+	case int32:
+		return float32(v), nil
+	}
+	return 0, fmt.Errorf("osc: %s: argument %d is %T, want a number",
+		m.Address, i+1, m.Args[i])
+}
+
+// Int returns argument i as an integer, accepting whole floats as well.
+func (m Message) Int(i int) (int, error) {
+	if i < len(m.Args) {
+		if v, ok := m.Args[i].(int32); ok {
+			return int(v), nil
+		}
+	}
+	f, err := m.Float32(i)
+	if err != nil {
+		return 0, err
+	}
+	if f != float32(int32(f)) {
+		return 0, fmt.Errorf("osc: %s: argument %d is %g, want an integer",
+			m.Address, i+1, f)
+	}
+	return int(f), nil
+}
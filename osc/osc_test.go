@@ -0,0 +1,138 @@
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func marshal(t *testing.T, m Message) []byte {
+	t.Helper()
+	packet, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+// bundle wraps packets in a bundle with an immediate time tag.
+func bundle(packets ...[]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("#bundle\x00")
+	binary.Write(&buf, binary.BigEndian, uint64(1))
+	for _, p := range packets {
+		binary.Write(&buf, binary.BigEndian, uint32(len(p)))
+		buf.Write(p)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []Message{
+		{Address: "/fluid/splat", Args: []interface{}{
+			float32(0.5), float32(0.25), float32(-100), float32(200),
+			float32(1), float32(0), float32(0.5)}},
+		{Address: "/fluid/pointer", Args: []interface{}{int32(3), float32(0.1), float32(0.9)}},
+		{Address: "/mixed", Args: []interface{}{"abc", "abcd", []byte{1, 2, 3, 4, 5}, true, false}},
+		{Address: "/no/args"},
+	}
+
+	for _, want := range tests {
+		packet := marshal(t, want)
+		if len(packet)%4 != 0 {
+			t.Errorf("%s: packet is %d bytes, want a multiple of 4", want.Address, len(packet))
+		}
+		got, err := Parse(packet)
+		if err != nil {
+			t.Errorf("%s: %v", want.Address, err)
+			continue
+		}
+		if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+			t.Errorf("Parse(MarshalBinary(%+v)) = %+v", want, got)
+		}
+	}
+}
+
+func TestMarshalGoTypes(t *testing.T) {
+	packet := marshal(t, Message{Address: "/x", Args: []interface{}{7, 0.5}})
+	got, err := Parse(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{int32(7), float32(0.5)}
+	if !reflect.DeepEqual(got[0].Args, want) {
+		t.Errorf("args = %#v, want %#v", got[0].Args, want)
+	}
+
+	if _, err := (Message{Address: "/x", Args: []interface{}{struct{}{}}}).MarshalBinary(); err == nil {
+		t.Error("MarshalBinary encoded a struct")
+	}
+}
+
+func TestParseBundle(t *testing.T) {
+	a := Message{Address: "/a", Args: []interface{}{int32(1)}}
+	b := Message{Address: "/b", Args: []interface{}{"two"}}
+	c := Message{Address: "/c"}
+	packet := bundle(marshal(t, a), bundle(marshal(t, b), marshal(t, c)))
+
+	got, err := Parse(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Message{a, b, c}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse(bundle) = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	good := marshal(t, Message{Address: "/fluid/pointer",
+		Args: []interface{}{int32(1), float32(0.5), float32(0.5)}})
+	blob := marshal(t, Message{Address: "/b", Args: []interface{}{[]byte{1, 2, 3, 4, 5, 6}}})
+
+	tests := []struct {
+		name   string
+		packet []byte
+	}{
+		{"empty", nil},
+		{"no address slash", []byte("abc\x00,\x00\x00\x00")},
+		{"unterminated address", []byte("/abc")},
+		{"unpadded address", []byte("/abcd\x00")},
+		{"type tags without comma", []byte("/a\x00\x00i\x00\x00\x00\x00\x00\x00\x01")},
+		{"unsupported type tag", []byte("/a\x00\x00,d\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")},
+		{"truncated argument", good[:len(good)-2]},
+		{"missing argument", good[:len(good)-4]},
+		{"truncated blob", blob[:len(blob)-4]},
+		{"short bundle header", []byte("#bundle\x00\x00\x00")},
+		{"bundle element too big", append(bundle(), 0, 0, 1, 0, '/', 'a', 0, 0)},
+		{"bundle element unaligned", append(bundle(), 0, 0, 0, 3, '/', 'a', 0, 0)},
+		{"truncated bundle size", append(bundle(), 0, 0)},
+		{"bad message in bundle", bundle([]byte("nope"))},
+	}
+
+	for _, tt := range tests {
+		if got, err := Parse(tt.packet); err == nil {
+			t.Errorf("%s: Parse() = %+v, want an error", tt.name, got)
+		}
+	}
+}
+
+func TestArgConversions(t *testing.T) {
+	m := Message{Address: "/x", Args: []interface{}{int32(4), float32(2), float32(2.5), "s"}}
+
+	if f, err := m.Float32(0); err != nil || f != 4 {
+		t.Errorf("Float32(int) = %v, %v, want 4", f, err)
+	}
+	if i, err := m.Int(1); err != nil || i != 2 {
+		t.Errorf("Int(whole float) = %v, %v, want 2", i, err)
+	}
+	if _, err := m.Int(2); err == nil {
+		t.Error("Int accepted 2.5")
+	}
+	if _, err := m.Float32(3); err == nil {
+		t.Error("Float32 accepted a string")
+	}
+	if _, err := m.Float32(4); err == nil {
+		t.Error("Float32 accepted a missing argument")
+	}
+}
@@ -0,0 +1,49 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/osc"
+)
+
+// Drives a simulation started with -osc :9000 over loopback
+// go run oscSendExample.go 127.0.0.1:9000
+func main() {
+	addr := "127.0.0.1:9000"
+	if len(os.Args) > 1 {
+		addr = os.Args[1]
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	send(conn, "/fluid/config/CURL", 50)
+	send(conn, "/fluid/splat", 0.5, 0.5, 0.0, 500.0, 1.0, 0.2, 0.2)
+
+	// Draw a circle with pointer 0.
+	for i := 0; i <= 120; i++ {
+		t := float64(i) / 120 * 2 * math.Pi
+		send(conn, "/fluid/pointer", 0, 0.5+0.3*math.Cos(t), 0.5+0.3*math.Sin(t))
+		time.Sleep(16 * time.Millisecond)
+	}
+	send(conn, "/fluid/pointer/up", 0)
+}
+
+func send(conn net.Conn, address string, args ...interface{}) {
+	packet, err := osc.Message{Address: address, Args: args}.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	if _, err := conn.Write(packet); err != nil {
+		panic(err)
+	}
+}
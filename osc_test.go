@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+	"github.com/NicholasBlaskey/go-fluid-simulation/osc"
+)
+
+// pointerRecorder stands in for the simulator's pointer queue.
+type pointerRecorder chan fluid.PointerEvent
+
+func (r pointerRecorder) PushPointerEvent(ev fluid.PointerEvent) {
+	r <- ev
+}
+
+// controlRecorder stands in for the main thread, keeping the splats and
+// config changes it is handed.
+type controlRecorder struct {
+	splats  []oscSplat
+	configs [][2]string // name, value
+}
+
+type oscSplat struct {
+	x, y, dx, dy float32
+	col          mgl.Vec3
+}
+
+func (r *controlRecorder) splat(x, y, dx, dy float32, col mgl.Vec3) {
+	r.splats = append(r.splats, oscSplat{x, y, dx, dy, col})
+}
+
+func (r *controlRecorder) setConfig(name, value string) {
+	r.configs = append(r.configs, [2]string{name, value})
+}
+
+func TestServeOSC(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	events := make(pointerRecorder, 16)
+	go serveOSC(conn, events, &controlRecorder{})
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	send := func(packet []byte) {
+		if _, err := client.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sendMessage := func(address string, args ...interface{}) {
+		packet, err := osc.Message{Address: address, Args: args}.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		send(packet)
+	}
+
+	// Neither a malformed packet nor a message missing arguments stops the
+	// listener.
+	send([]byte("not osc"))
+	sendMessage("/fluid/pointer", 2)
+	sendMessage("/fluid/pointer", 2, 0.25, 0.75)
+	sendMessage("/fluid/pointer/up", 2.0)
+
+	want := []fluid.PointerEvent{
+		{Type: fluid.PointerMove, ID: oscPointerBase + 2, X: 0.25, Y: 0.75},
+		{Type: fluid.PointerUp, ID: oscPointerBase + 2},
+	}
+	for _, w := range want {
+		select {
+		case ev := <-events:
+			if ev.Time.IsZero() {
+				t.Error("event has no time")
+			}
+			ev.Time = time.Time{}
+			if ev != w {
+				t.Errorf("got %+v, want %+v", ev, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event, want %+v", w)
+		}
+	}
+}
+
+func TestHandleOSCErrors(t *testing.T) {
+	events := make(pointerRecorder, 1)
+	tests := []osc.Message{
+		{Address: "/fluid/unknown"},
+		{Address: "/fluid/pointer", Args: []interface{}{int32(1), float32(0.5)}},
+		{Address: "/fluid/pointer", Args: []interface{}{float32(1.5), float32(0), float32(0)}},
+		{Address: "/fluid/pointer/up", Args: []interface{}{"one"}},
+		{Address: "/fluid/splat", Args: []interface{}{float32(0.5)}},
+	}
+
+	control := &controlRecorder{}
+	for _, m := range tests {
+		if err := handleOSC(m, events, control); err == nil {
+			t.Errorf("handleOSC(%+v) succeeded", m)
+		}
+	}
+	if len(events) > 0 {
+		t.Errorf("rejected messages queued %+v", <-events)
+	}
+	if len(control.splats) > 0 || len(control.configs) > 0 {
+		t.Errorf("rejected messages passed on %+v", control)
+	}
+}
+
+func TestHandleOSCSplat(t *testing.T) {
+	control := &controlRecorder{}
+	m := osc.Message{Address: "/fluid/splat", Args: []interface{}{
+		float32(0.25), float32(0.75), int32(100), float32(-50),
+		float32(1), float32(0.5), float32(0),
+	}}
+	if err := handleOSC(m, make(pointerRecorder), control); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []oscSplat{{0.25, 0.75, 100, -50, mgl.Vec3{1, 0.5, 0}}}
+	if !reflect.DeepEqual(control.splats, want) {
+		t.Errorf("splats %+v, want %+v", control.splats, want)
+	}
+}
+
+func TestHandleOSCConfig(t *testing.T) {
+	tests := []struct {
+		address string
+		args    []interface{}
+		want    [2]string
+	}{
+		{"/fluid/config/CURL", []interface{}{float32(30)}, [2]string{"CURL", "30"}},
+		{"/fluid/config/PRESSURE_ITERATIONS", []interface{}{int32(20)},
+			[2]string{"PRESSURE_ITERATIONS", "20"}},
+		{"/fluid/config/BACK_COLOR", []interface{}{float32(0.5), float32(0.25), float32(0)},
+			[2]string{"BACK_COLOR", "0.5,0.25,0"}},
+		{"/fluid/config/SHADING", []interface{}{"false"}, [2]string{"SHADING", "false"}},
+	}
+
+	for _, tt := range tests {
+		control := &controlRecorder{}
+		m := osc.Message{Address: tt.address, Args: tt.args}
+		if err := handleOSC(m, make(pointerRecorder), control); err != nil {
+			t.Errorf("%s: %v", tt.address, err)
+			continue
+		}
+		if len(control.configs) != 1 || control.configs[0] != tt.want {
+			t.Errorf("%s: config changes %q, want %q", tt.address, control.configs, tt.want)
+		}
+	}
+}
+
+func TestRunOnMainDrops(t *testing.T) {
+	ran := 0
+	for i := 0; i < cap(tasks); i++ {
+		if !runOnMain(func() { ran++ }) {
+			t.Fatalf("task %d dropped with room in the queue", i)
+		}
+	}
+	if runOnMain(func() { ran++ }) {
+		t.Error("runOnMain queued a task past the queue size")
+	}
+	if n := droppedTasks.Load(); n != 1 {
+		t.Errorf("%d dropped tasks counted, want 1", n)
+	}
+
+	runTasks()
+	if ran != cap(tasks) || droppedTasks.Load() != 0 {
+		t.Errorf("runTasks ran %d tasks and left %d counted, want %d and 0",
+			ran, droppedTasks.Load(), cap(tasks))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+)
+
+// recording is the session being written, if any. Only the main thread
+// touches it.
+var recording struct {
+	file     *os.File
+	recorder *fluid.Recorder
+}
+
+// startRecording writes the session to path from now on. seed must be what
+// the math/rand source was last seeded with.
+func startRecording(path string, seed int64) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	recording.file = file
+	recording.recorder = fluid.NewRecorder(file, seed)
+	sim.Record(recording.recorder)
+	log.Println("recording to", path)
+	return nil
+}
+
+func stopRecording() {
+	if recording.file == nil {
+		return
+	}
+
+	sim.Record(nil)
+	err := recording.recorder.Flush()
+	if closeErr := recording.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Println("recording:", err)
+	} else {
+		log.Println("recording written to", recording.file.Name())
+	}
+	recording.file, recording.recorder = nil, nil
+}
+
+// toggleRecording starts a session named after the time, or ends the
+// current one. The random source is seeded afresh so the session knows the
+// seed its pointer colours come from.
+func toggleRecording() {
+	if recording.file != nil {
+		stopRecording()
+		return
+	}
+
+	seed := time.Now().UTC().UnixNano()
+	rand.Seed(seed)
+	path := fmt.Sprintf("session-%s.jsonl", time.Now().Format("20060102-150405"))
+	if err := startRecording(path, seed); err != nil {
+		log.Println("recording:", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+)
+
+// watchConfig polls the -config file and sends every edit that loads
+// cleanly, with the command line overrides applied again on top. Edits that
+// fail are logged and dropped so the running config stays in place.
+func watchConfig(options *configOptions, interval time.Duration) <-chan fluid.Config {
+	reloads := make(chan fluid.Config, 1)
+
+	go func() {
+		var lastMod time.Time
+		if info, err := os.Stat(*options.path); err == nil {
+			lastMod = info.ModTime()
+		}
+
+		for range time.Tick(interval) {
+			info, err := os.Stat(*options.path)
+			if err != nil || info.ModTime().Equal(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+
+			config, err := options.reload()
+			if err != nil {
+				log.Println("config edit rejected:", err)
+				continue
+			}
+
+			// Only the newest config matters if the render loop falls behind.
+			select {
+			case <-reloads:
+			default:
+			}
+			reloads <- config
+		}
+	}()
+
+	return reloads
+}
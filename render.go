@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+)
+
+// runRender is the offline batch mode: it steps the simulation a fixed
+// number of frames at a fixed dt and writes every displayed frame to
+// outDir/frame_00000.png, frame_00001.png, ...
+func runRender(args []string) {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	frames := flags.Int("frames", 300, "number of frames to render")
+	dt := flags.Float64("dt", 0.016666, "time step per frame in seconds")
+	outDir := flags.String("out", "frames", "directory the PNG files are written to")
+	w := flags.Int("width", width, "frame width in pixels")
+	h := flags.Int("height", height, "frame height in pixels")
+	backend := flags.String("backend", "cpu",
+		"solver to use, cpu or gl (gl still needs a display for its hidden window)")
+	seed := flags.Int64("seed", 1, "seed for the random splats")
+	options := addConfigFlags(flags)
+	flags.Parse(args)
+
+	config, err := options.load()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalln(err)
+	}
+	rand.Seed(*seed)
+
+	var solver fluid.Solver
+	switch *backend {
+	case "cpu":
+		solver = fluid.NewCPUSolver(*w, *h, config)
+	case "gl":
+		initHiddenGL(*w, *h)
+		defer glfw.Terminate()
+		solver = fluid.NewSimulator(*w, *h, config)
+	default:
+		log.Fatalf("unknown backend %q, want cpu or gl\n", *backend)
+	}
+
+	for i := 0; i < 5; i++ {
+		solver.MultipleSplats(3)
+	}
+
+	for i := 0; i < *frames; i++ {
+		if (i+1)%1000 == 0 {
+			solver.MultipleSplats(3)
+		}
+		solver.Step(float32(*dt))
+
+		path := filepath.Join(*outDir, fmt.Sprintf("frame_%05d.png", i))
+		if err := writePNG(path, solver); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	log.Printf("wrote %d frames to %s\n", *frames, *outDir)
+}
+
+func writePNG(path string, solver fluid.Solver) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, solver.Snapshot()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// initHiddenGL creates a context without showing a window.
+func initHiddenGL(width, height int) {
+	if err := glfw.Init(); err != nil {
+		panic(err)
+	}
+
+	glfw.WindowHint(glfw.ContextVersionMajor, 4)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	glfw.WindowHint(glfw.Visible, glfw.False)
+	window, err := glfw.CreateWindow(width, height, "Fluid sim", nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	window.MakeContextCurrent()
+
+	if err := gl.Init(); err != nil {
+		panic(err)
+	}
+}
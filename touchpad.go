@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/NicholasBlaskey/go-fluid-simulation/evdev"
+	"github.com/NicholasBlaskey/go-fluid-simulation/fluid"
+)
+
+// readTouchPad finds the touch device picked by selector (see
+// evdev.FindDevice) and queues its absolute positions as pointer events for
+// the render loop. Reading /dev/input needs root or membership of the input
+// group.
+func readTouchPad(selector string) {
+	devices, err := evdev.ListDevices()
+	if err != nil {
+		log.Println("touchpad disabled:", err)
+		return
+	}
+	device, err := evdev.FindDevice(devices, selector)
+	if err != nil {
+		log.Println("touchpad disabled:", err)
+		return
+	}
+
+	file, err := os.Open(device.Path)
+	if err != nil {
+		log.Println("touchpad disabled:", err)
+		return
+	}
+	defer file.Close()
+
+	// The device knows its own capabilities, also when FindDevice was given
+	// a path it could not find listed. Without them fall back to single
+	// touch strokes that only start on BTN_TOUCH, so an unknown device
+	// cannot splat jumps by seeming to touch all the time.
+	known := true
+	if queried, err := evdev.QueryDevice(file); err == nil {
+		device = queried
+	} else if !device.HasAbs(evdev.ABS_X) {
+		log.Println("touchpad: capabilities of", device.Path, "unknown, "+
+			"assuming single touch with BTN_TOUCH:", err)
+		known = false
+	}
+
+	// Multitouch devices get one pointer per finger.
+	xAxis, yAxis := uint16(evdev.ABS_X), uint16(evdev.ABS_Y)
+	pressureAxis := uint16(evdev.ABS_PRESSURE)
+	multitouch := device.HasAbs(evdev.ABS_MT_POSITION_X) &&
+		device.HasAbs(evdev.ABS_MT_POSITION_Y)
+	if multitouch {
+		xAxis, yAxis = evdev.ABS_MT_POSITION_X, evdev.ABS_MT_POSITION_Y
+		pressureAxis = evdev.ABS_MT_PRESSURE
+	}
+
+	// Devices without pressure leave this zeroed, which normalizes every
+	// value to 0, meaning not reported.
+	pressureInfo, _ := evdev.GetAbsInfo(file, pressureAxis)
+
+	xInfo, err := evdev.GetAbsInfo(file, xAxis)
+	if err == nil {
+		var yInfo evdev.AbsInfo
+		if yInfo, err = evdev.GetAbsInfo(file, yAxis); err == nil {
+			log.Printf("touchpad %q at %s, x in [%d, %d], y in [%d, %d], "+
+				"pressure in [%d, %d], %.0fx%.0f mm (0 if unknown), multitouch %v\n",
+				device.Name, device.Path, xInfo.Minimum, xInfo.Maximum,
+				yInfo.Minimum, yInfo.Maximum, pressureInfo.Minimum,
+				pressureInfo.Maximum, xInfo.Length(), yInfo.Length(), multitouch)
+			axes := touchAxes{xInfo, yInfo, pressureInfo}
+			if multitouch {
+				readMultiTouchEvents(file, axes)
+			} else {
+				readTouchEvents(file, axes, device.HasKey(evdev.BTN_TOUCH) || !known)
+			}
+			return
+		}
+	}
+	log.Println("touchpad disabled:", err)
+}
+
+// touchAxes are the ranges events are normalized against.
+type touchAxes struct {
+	x, y, pressure evdev.AbsInfo
+}
+
+func (a touchAxes) event(typ fluid.PointerEventType, id int, when time.Time,
+	x, y, pressure int32) fluid.PointerEvent {
+
+	return fluid.PointerEvent{
+		Time:     when,
+		Type:     typ,
+		ID:       id,
+		X:        a.x.Normalize(x),
+		Y:        a.y.Normalize(y),
+		Pressure: a.pressure.Normalize(pressure),
+	}
+}
+
+// readTouchEvents follows a single touch device. Strokes start and end with
+// BTN_TOUCH, devices without it are treated as always touching.
+func readTouchEvents(r io.Reader, axes touchAxes, hasTouch bool) {
+	decoder := evdev.NewDecoder(r)
+
+	// Only axes that changed are reported, so keep the last values.
+	x, y, pressure := int32(-1), int32(-1), int32(0)
+	touching, stroke := !hasTouch, false
+	for {
+		frame, err := decoder.ReadFrame()
+		if err != nil {
+			log.Println("touchpad:", err)
+			return
+		}
+		if len(frame) == 0 {
+			continue
+		}
+
+		moved := false
+		for _, ev := range frame {
+			switch {
+			case ev.Type == evdev.EV_KEY && ev.Code == evdev.BTN_TOUCH:
+				touching = ev.Value != 0
+			case ev.Type == evdev.EV_ABS && ev.Code == evdev.ABS_X:
+				x, moved = ev.Value, true
+			case ev.Type == evdev.EV_ABS && ev.Code == evdev.ABS_Y:
+				y, moved = ev.Value, true
+			case ev.Type == evdev.EV_ABS && ev.Code == evdev.ABS_PRESSURE:
+				pressure = ev.Value
+			}
+		}
+
+		ev := axes.event(fluid.PointerMove, 0, frame[len(frame)-1].Time,
+			x, y, pressure)
+		switch {
+		case !touching:
+			if !stroke {
+				continue
+			}
+			ev.Type, stroke = fluid.PointerUp, false
+		case x == -1 || y == -1:
+			continue
+		case !stroke:
+			ev.Type, stroke = fluid.PointerDown, true
+		case !moved:
+			continue
+		}
+		sim.PushPointerEvent(ev)
+	}
+}
+
+func readMultiTouchEvents(r io.Reader, axes touchAxes) {
+	decoder := evdev.NewDecoder(r)
+	mt := evdev.NewMultiTouch()
+	for {
+		frame, err := decoder.ReadFrame()
+		if err != nil {
+			log.Println("touchpad:", err)
+			return
+		}
+		if len(frame) == 0 {
+			continue
+		}
+
+		when := frame[len(frame)-1].Time
+		for _, contact := range mt.Update(frame) {
+			ev := axes.event(fluid.PointerMove, int(contact.ID), when,
+				contact.X, contact.Y, contact.Pressure)
+			switch {
+			case contact.Ended:
+				ev.Type = fluid.PointerUp
+			case contact.Began:
+				ev.Type = fluid.PointerDown
+			case !contact.Moved:
+				continue
+			}
+			sim.PushPointerEvent(ev)
+		}
+	}
+}
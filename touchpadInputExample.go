@@ -1,71 +1,49 @@
+//go:build ignore
+// +build ignore
+
 package main
 
 import (
 	"fmt"
-	"os/exec"
-
-	"bufio"
-	"io"
-	"strconv"
-	"strings"
+	"os"
 
-	"time"
+	"github.com/NicholasBlaskey/go-fluid-simulation/evdev"
 )
 
-// Need to run with sudo because of evtest
-// go build test.go && ./test
+// Prints the absolute touchpad position, needs read access to /dev/input
+// go run touchpadInputExample.go /dev/input/event8
 func main() {
-	go readTouchPad("8")
-
 	// x \in [0, 3840]
 	// y \in [0, 1932]
 	// where left => x = 0
 	// and up => y = 0
 
-	time.Sleep(1000 * time.Second)
-}
-
-func readTouchPad(inputNum string) {
-	cmd := exec.Command("evtest")
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		panic(err)
-	}
-	stdin, err := cmd.StdinPipe()
+	file, err := os.Open(os.Args[1])
 	if err != nil {
 		panic(err)
 	}
+	defer file.Close()
 
-	if err := cmd.Start(); err != nil {
-		panic(err)
-	}
-	r := bufio.NewReader(stdout)
-	//go func() {
-	io.WriteString(stdin, inputNum)
-	stdin.Close()
-	//}()
-
-	x, y := -1, -1
+	decoder := evdev.NewDecoder(file)
 	for {
-		in, _ := r.ReadString('\n')
-
-		if strings.Contains(in, "(ABS_X),") {
-			split := strings.Split(in, " ")
-			x, _ = strconv.Atoi(strings.Trim(split[len(split)-1], "\n "))
-		} else if strings.Contains(in, "(ABS_Y),") {
-			split := strings.Split(in, " ")
-			y, _ = strconv.Atoi(strings.Trim(split[len(split)-1], "\n "))
+		frame, err := decoder.ReadFrame()
+		if err != nil {
+			panic(err)
 		}
 
-		if x == -1 || y == -1 {
-			continue
+		for _, ev := range frame {
+			if ev.Type == evdev.EV_ABS &&
+				(ev.Code == evdev.ABS_X || ev.Code == evdev.ABS_Y) {
+				doSomething(ev.Code, ev.Value)
+			}
 		}
-
-		doSomething(x, y)
-		x, y = -1, -1
 	}
 }
 
-func doSomething(x, y int) {
-	fmt.Println(x, y)
+func doSomething(code uint16, value int32) {
+	if code == evdev.ABS_X {
+		fmt.Println("x", value)
+	} else {
+		fmt.Println("y", value)
+	}
 }